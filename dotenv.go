@@ -0,0 +1,211 @@
+// Copyright (c) 2016 Kelsey Hightower and others. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package envconfig
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ProcessFile is the same as Process but also reads values from the dotenv
+// file at path. Variables set in the environment take precedence over the
+// file, so deploy time overrides still win.
+//
+// The file holds one KEY=VALUE assignment per line. Blank lines and lines
+// starting with # are ignored, as is a leading "export ". Values may be
+// wrapped in single quotes, which are taken literally, or double quotes,
+// which understand the escapes \n, \r, \t, \", \\ and \$. Unquoted values end
+// at the first # preceded by whitespace.
+func ProcessFile(prefix string, spec interface{}, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	vars, err := parseDotEnv(f)
+	if err != nil {
+		return fmt.Errorf("envconfig: parsing %s: %v", path, err)
+	}
+
+	return ProcessWith(prefix, spec, MultiSource(envLookup, func(key string) (string, bool) {
+		value, ok := vars[key]
+		return value, ok
+	}))
+}
+
+// SampleEnv returns a dotenv file listing every variable spec reads, ready to
+// be edited and loaded with ProcessFile. Each variable is assigned its default
+// and preceded by its description as a comment. Required variables without a
+// default are left empty and marked # REQUIRED. Secret variables are always
+// left empty and marked # SECRET, so that no real default is written out.
+// Defaults that are expanded, such as $fn:hostname or ${HOME}/cache, would be
+// read back literally, so they are only shown in a # default: comment and the
+// empty assignment is commented out to keep them in effect. Variables of list
+// elements are commented out, with <N> for the index.
+func SampleEnv(prefix string, spec interface{}) (string, error) {
+	keys, err := Keys(prefix, spec)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		if k.Description != "" {
+			for _, line := range strings.Split(k.Description, "\n") {
+				fmt.Fprintf(&b, "# %s\n", line)
+			}
+		}
+		value := k.Default
+		if k.Secret {
+			value = ""
+		}
+		expanded := strings.Contains(value, "$")
+		if k.Required && value == "" {
+			b.WriteString("# REQUIRED\n")
+		}
+		if k.Secret {
+			b.WriteString("# SECRET\n")
+		}
+		if expanded {
+			fmt.Fprintf(&b, "# default: %s\n", value)
+			value = ""
+		}
+		// list elements are numbered by the user, so their keys are only a
+		// pattern to copy
+		if expanded || strings.Contains(k.Key, "<N>") {
+			b.WriteString("# ")
+		}
+		fmt.Fprintf(&b, "%s=%s\n", k.Key, quoteDotEnvValue(value))
+	}
+	return b.String(), nil
+}
+
+// quoteDotEnvValue double quotes s if parseDotEnvValue would not read it back
+// as is.
+func quoteDotEnvValue(s string) string {
+	if s == strings.TrimSpace(s) && !strings.ContainsAny(s, "#'\"\\\n\r\t") {
+		return s
+	}
+	r := strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n", "\r", "\\r", "\t", "\\t")
+	return `"` + r.Replace(s) + `"`
+}
+
+// parseDotEnv reads dotenv formatted assignments from r.
+func parseDotEnv(r io.Reader) (map[string]string, error) {
+	vars := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "export ") {
+			line = strings.TrimSpace(line[len("export "):])
+		}
+
+		eq := strings.Index(line, "=")
+		if eq < 0 {
+			return nil, fmt.Errorf("line %d: missing '=' in %q", n, line)
+		}
+		key := strings.TrimSpace(line[:eq])
+		if !isDotEnvKey(key) {
+			return nil, fmt.Errorf("line %d: invalid key %q", n, key)
+		}
+		value, err := parseDotEnvValue(strings.TrimSpace(line[eq+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		vars[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return vars, nil
+}
+
+// parseDotEnvValue unquotes the value part of an assignment.
+func parseDotEnvValue(s string) (string, error) {
+	if s == "" {
+		return "", nil
+	}
+
+	var (
+		value string
+		rest  string
+	)
+	switch s[0] {
+	case '\'':
+		end := strings.IndexByte(s[1:], '\'')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated single quoted value %s", s)
+		}
+		value, rest = s[1:end+1], s[end+2:]
+	case '"':
+		var b strings.Builder
+		i := 1
+		for ; i < len(s) && s[i] != '"'; i++ {
+			if s[i] != '\\' {
+				b.WriteByte(s[i])
+				continue
+			}
+			i++
+			if i == len(s) {
+				break
+			}
+			switch s[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case '"', '\\', '$':
+				b.WriteByte(s[i])
+			default:
+				return "", fmt.Errorf("unknown escape sequence \\%c", s[i])
+			}
+		}
+		if i >= len(s) {
+			return "", fmt.Errorf("unterminated double quoted value %s", s)
+		}
+		value, rest = b.String(), s[i+1:]
+	default:
+		if i := strings.Index(s, " #"); i >= 0 {
+			s = s[:i]
+		}
+		if i := strings.Index(s, "\t#"); i >= 0 {
+			s = s[:i]
+		}
+		return strings.TrimSpace(s), nil
+	}
+
+	rest = strings.TrimSpace(rest)
+	if rest != "" && !strings.HasPrefix(rest, "#") {
+		return "", fmt.Errorf("unexpected characters %q after quoted value", rest)
+	}
+	return value, nil
+}
+
+func isDotEnvKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for i, r := range key {
+		switch {
+		case r == '_', r >= 'A' && r <= 'Z', r >= 'a' && r <= 'z':
+		case i > 0 && (r == '.' || r >= '0' && r <= '9'):
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,181 @@
+// Copyright (c) 2016 Kelsey Hightower and others. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package envconfig
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func writeDotEnv(t *testing.T, content string) string {
+	f, err := ioutil.TempFile("", "envconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+	return f.Name()
+}
+
+func TestParseDotEnv(t *testing.T) {
+	vars, err := parseDotEnv(strings.NewReader(`
+# a comment
+PLAIN=value
+SPACED = spaced value # trailing comment
+export EXPORTED=yes
+SINGLE='literal \n $HOME'
+DOUBLE="line\nbreak \"quoted\" \$HOME" # comment
+HASH=a#b
+EMPTY=
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"PLAIN":    "value",
+		"SPACED":   "spaced value",
+		"EXPORTED": "yes",
+		"SINGLE":   `literal \n $HOME`,
+		"DOUBLE":   "line\nbreak \"quoted\" $HOME",
+		"HASH":     "a#b",
+		"EMPTY":    "",
+	}
+	if len(vars) != len(expected) {
+		t.Errorf("expected %d variables, got %d: %v", len(expected), len(vars), vars)
+	}
+	for k, v := range expected {
+		if vars[k] != v {
+			t.Errorf("%s: expected %q, got %q", k, v, vars[k])
+		}
+	}
+}
+
+func TestParseDotEnvMalformed(t *testing.T) {
+	for _, content := range []string{
+		"NOEQUALS",
+		"=value",
+		"BAD KEY=value",
+		`OPEN="unterminated`,
+		`OPEN='unterminated`,
+		`TRAILING="quoted" junk`,
+		`ESCAPE="\q"`,
+	} {
+		if _, err := parseDotEnv(strings.NewReader(content)); err == nil {
+			t.Errorf("expected error for %q", content)
+		}
+	}
+}
+
+func TestProcessFile(t *testing.T) {
+	path := writeDotEnv(t, "ENV_CONFIG_PORT=8080\nENV_CONFIG_USER=file\nENV_CONFIG_REQUIREDVAR=foo\n")
+	defer os.Remove(path)
+
+	var s Specification
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_USER", "env")
+	if err := ProcessFile("env_config", &s, path); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if s.Port != 8080 {
+		t.Errorf("expected %d, got %d", 8080, s.Port)
+	}
+	if s.User != "env" {
+		t.Errorf("expected environment to win, got %s", s.User)
+	}
+	if s.RequiredVar != "foo" {
+		t.Errorf("expected %s, got %s", "foo", s.RequiredVar)
+	}
+}
+
+func TestProcessFileMalformed(t *testing.T) {
+	path := writeDotEnv(t, "ENV_CONFIG_PORT\n")
+	defer os.Remove(path)
+
+	var s Specification
+	os.Clearenv()
+	err := ProcessFile("env_config", &s, path)
+	if err == nil || !strings.Contains(err.Error(), path) || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("expected error naming %s line 1, got %v", path, err)
+	}
+}
+
+func TestSampleEnv(t *testing.T) {
+	var s struct {
+		Port     int    `default:"8080" desc:"listen port"`
+		DSN      string `required:"true"`
+		Password string `secret:"true" default:"hunter2"`
+		Greeting string `default:"hello # world"`
+		Servers  []struct {
+			Host string
+		}
+	}
+	sample, err := SampleEnv("env_config", &s)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	want := `# listen port
+ENV_CONFIG_PORT=8080
+
+# REQUIRED
+ENV_CONFIG_DSN=
+
+# SECRET
+ENV_CONFIG_PASSWORD=
+
+ENV_CONFIG_GREETING="hello # world"
+
+# ENV_CONFIG_SERVERS_<N>_HOST=
+`
+	if sample != want {
+		t.Errorf("expected %q, got %q", want, sample)
+	}
+
+	vars, err := parseDotEnv(strings.NewReader(sample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vars["ENV_CONFIG_GREETING"] != "hello # world" {
+		t.Errorf("expected the default to read back, got %q", vars["ENV_CONFIG_GREETING"])
+	}
+}
+
+func TestSampleEnvExpandedDefaults(t *testing.T) {
+	RegisterDefaultFunc("sample", func() (string, error) { return "node-1", nil })
+	var s struct {
+		Node  string `default:"$fn:sample"`
+		Cache string `default:"${HOME}/cache"`
+	}
+	sample, err := SampleEnv("env_config", &s)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	want := `# default: $fn:sample
+# ENV_CONFIG_NODE=
+
+# default: ${HOME}/cache
+# ENV_CONFIG_CACHE=
+`
+	if sample != want {
+		t.Errorf("expected %q, got %q", want, sample)
+	}
+
+	path := writeDotEnv(t, sample)
+	defer os.Remove(path)
+
+	os.Clearenv()
+	os.Setenv("HOME", "/home/gopher")
+	if err := ProcessFile("env_config", &s, path); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Node != "node-1" || s.Cache != "/home/gopher/cache" {
+		t.Errorf("expected the defaults to apply after a round trip, got %q and %q", s.Node, s.Cache)
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2016 Kelsey Hightower and others. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package envconfig
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// FieldSpec declares a configuration variable for ProcessSpec, for
+// configuration whose schema is only known at run time.
+type FieldSpec struct {
+	// Name is the name of the variable without the prefix. It is upper
+	// cased like the name of a struct field.
+	Name string
+
+	// Type is the type of the value, any type a struct field may have.
+	Type reflect.Type
+
+	Default     string
+	Required    bool
+	Description string
+
+	// Tag holds any other tags, such as separator, format or secret, in the
+	// syntax of a struct tag.
+	Tag reflect.StructTag
+}
+
+// tags returns the tags of the field with Default, Required and Description
+// written in front so that they take precedence.
+func (f FieldSpec) tags() reflect.StructTag {
+	var b strings.Builder
+	if f.Default != "" {
+		fmt.Fprintf(&b, "default:%s ", strconv.Quote(f.Default))
+	}
+	if f.Required {
+		b.WriteString(`required:"true" `)
+	}
+	if f.Description != "" {
+		fmt.Fprintf(&b, "desc:%s ", strconv.Quote(f.Description))
+	}
+	return reflect.StructTag(b.String() + string(f.Tag))
+}
+
+// ProcessSpec is the same as ProcessWith but reads the variables declared by
+// fields instead of the fields of a struct. It returns a value of the declared
+// type for every variable that is set or has a default, keyed by the name of
+// the variable. A nil lookup reads from the environment.
+func ProcessSpec(prefix string, fields []FieldSpec, lookup func(key string) (string, bool)) (map[string]interface{}, error) {
+	if lookup == nil {
+		lookup = envLookup
+	}
+	prefix = strings.TrimSpace(prefix)
+
+	p := &Processor{}
+	values := make(map[string]interface{}, len(fields))
+	for _, f := range fields {
+		if f.Name == "" || f.Type == nil {
+			return nil, fmt.Errorf("envconfig: field spec %q needs a name and a type", f.Name)
+		}
+
+		info := varInfo{
+			Name:  f.Name,
+			Key:   strings.ToUpper(f.Name),
+			Field: reflect.New(f.Type).Elem(),
+			Tags:  f.tags(),
+		}
+		if prefix != "" {
+			info.Key = strings.ToUpper(prefix) + p.separator() + info.Key
+		}
+		if err := prepareInfo(&info); err != nil {
+			return nil, err
+		}
+
+		r, ok, err := p.resolveInfo(info, plainLookup(lookup))
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		if err := p.assignInfo(info, r); err != nil {
+			return nil, err
+		}
+		values[f.Name] = info.Field.Interface()
+	}
+	return values, nil
+}
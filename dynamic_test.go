@@ -0,0 +1,76 @@
+// Copyright (c) 2016 Kelsey Hightower and others. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package envconfig
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestProcessSpec(t *testing.T) {
+	env := map[string]string{
+		"PLUGIN_PORT":    "8080",
+		"PLUGIN_HOSTS":   "a;b",
+		"PLUGIN_TIMEOUT": "5s",
+	}
+	lookup := func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+	fields := []FieldSpec{
+		{Name: "port", Type: reflect.TypeOf(0), Required: true},
+		{Name: "hosts", Type: reflect.TypeOf([]string{}), Tag: `separator:";"`},
+		{Name: "timeout", Type: reflect.TypeOf(time.Duration(0))},
+		{Name: "level", Type: reflect.TypeOf(""), Default: "info"},
+		{Name: "debug", Type: reflect.TypeOf(false)},
+	}
+
+	values, err := ProcessSpec("plugin", fields, lookup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != 4 {
+		t.Errorf("expected 4 values, got %v", values)
+	}
+	if values["port"] != 8080 {
+		t.Errorf("expected %d, got %#v", 8080, values["port"])
+	}
+	if hosts, ok := values["hosts"].([]string); !ok || len(hosts) != 2 || hosts[1] != "b" {
+		t.Errorf("expected [a b], got %#v", values["hosts"])
+	}
+	if values["timeout"] != 5*time.Second {
+		t.Errorf("expected %v, got %#v", 5*time.Second, values["timeout"])
+	}
+	if values["level"] != "info" {
+		t.Errorf("expected %q, got %#v", "info", values["level"])
+	}
+	if _, ok := values["debug"]; ok {
+		t.Errorf("expected unset variable to be left out, got %#v", values["debug"])
+	}
+}
+
+func TestProcessSpecErrors(t *testing.T) {
+	lookup := func(key string) (string, bool) {
+		if key == "PLUGIN_PORT" {
+			return "http", true
+		}
+		return "", false
+	}
+
+	_, err := ProcessSpec("plugin", []FieldSpec{{Name: "port", Type: reflect.TypeOf(0)}}, lookup)
+	if v, ok := err.(*ParseError); !ok || v.KeyName != "PLUGIN_PORT" {
+		t.Errorf("expected ParseError for PLUGIN_PORT, got %v", err)
+	}
+
+	_, err = ProcessSpec("plugin", []FieldSpec{{Name: "token", Type: reflect.TypeOf(""), Required: true, Description: "api token"}}, lookup)
+	if want := "required key PLUGIN_TOKEN missing value (api token)"; err == nil || err.Error() != want {
+		t.Errorf("expected %q, got %v", want, err)
+	}
+
+	if _, err := ProcessSpec("plugin", []FieldSpec{{Name: "untyped"}}, lookup); err == nil {
+		t.Error("expected an error for a field spec without a type")
+	}
+}
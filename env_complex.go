@@ -0,0 +1,7 @@
+// +build go1.15
+
+package envconfig
+
+import "strconv"
+
+var parseComplex = strconv.ParseComplex
@@ -0,0 +1,8 @@
+// +build !go1.15
+
+package envconfig
+
+// strconv.ParseComplex is only available in go1.15 or newer
+func parseComplex(s string, bitSize int) (complex128, error) {
+	return 0, ErrUnsupportedFieldType
+}
@@ -5,237 +5,1997 @@
 package envconfig
 
 import (
+	"context"
 	"encoding"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/url"
 	"os"
 	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 )
 
 // ErrInvalidSpecification indicates that a specification is of the wrong type.
+// Every function that takes a specification returns it, rather than
+// panicking, for anything but a non-nil pointer to a struct.
 var ErrInvalidSpecification = errors.New("specification must be a struct pointer")
 
+// ErrUnsupportedFieldType indicates that a value was found for a field whose
+// type envconfig does not know how to assign.
+var ErrUnsupportedFieldType = errors.New("unsupported field type")
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+	fileModeType = reflect.TypeOf(os.FileMode(0))
+	ipNetType    = reflect.TypeOf(net.IPNet{})
+	bigIntType   = reflect.TypeOf(big.Int{})
+	bigFloatType = reflect.TypeOf(big.Float{})
+)
+
 var gatherRegexp = regexp.MustCompile("([^A-Z]+|[A-Z]+[^A-Z]+|[A-Z]+)")
 var acronymRegexp = regexp.MustCompile("([A-Z]+)([A-Z][^A-Z]+)")
 
-// A ParseError occurs when an environment variable cannot be converted to
-// the type required by a struct field during assignment.
-type ParseError struct {
-	KeyName   string
-	FieldName string
-	TypeName  string
-	Value     string
-	Err       error
+// A ParseError occurs when an environment variable cannot be converted to
+// the type required by a struct field during assignment. Err holds the
+// error of the conversion, such as a *strconv.NumError, and is returned by
+// Unwrap.
+type ParseError struct {
+	KeyName   string
+	FieldName string
+	TypeName  string
+	Value     string
+	Err       error
+}
+
+// Decoder has the same semantics as Setter, but takes higher precedence.
+// It is provided for historical compatibility.
+type Decoder interface {
+	Decode(value string) error
+}
+
+// Setter is implemented by types can self-deserialize values.
+// Any type that implements flag.Value also implements Setter.
+type Setter interface {
+	Set(value string) error
+}
+
+// PostProcessor is implemented by specifications that normalize or validate
+// their fields once they are set. After every field has been assigned,
+// PostProcess is called once for each variable of the specification in the
+// order the fields are declared, with the name of its field. Fields of nested
+// structs are included. An error aborts processing and is returned as is.
+type PostProcessor interface {
+	PostProcess(fieldName string) error
+}
+
+// Validator is implemented by specifications that check invariants spanning
+// several fields. Validate is called once, after every field has been assigned
+// and required fields have been checked, and after any PostProcess calls. An
+// error aborts processing and is returned as is.
+type Validator interface {
+	Validate() error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("envconfig.Process: assigning %[1]s to %[2]s: converting '%[3]s' to type %[4]s. details: %[5]s", e.KeyName, e.FieldName, e.Value, e.TypeName, e.Err)
+}
+
+// Unwrap returns the underlying conversion error.
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// ErrRequired is matched by errors.Is for every RequiredError.
+var ErrRequired = errors.New("required key missing value")
+
+// A RequiredError occurs when a required variable is not set. Use errors.As
+// to tell it apart from a ParseError, or errors.Is with ErrRequired.
+type RequiredError struct {
+	KeyName   string // the key named in the message
+	FieldName string
+
+	aliases     []string
+	description string
+}
+
+func (e *RequiredError) Error() string {
+	key := e.KeyName
+	if len(e.aliases) > 0 {
+		key = fmt.Sprintf("%s (or %s)", key, strings.Join(e.aliases, ", "))
+	}
+	if e.description != "" {
+		return fmt.Sprintf("required key %s missing value (%s)", key, e.description)
+	}
+	return fmt.Sprintf("required key %s missing value", key)
+}
+
+// Is reports whether target is ErrRequired.
+func (e *RequiredError) Is(target error) bool {
+	return target == ErrRequired
+}
+
+// rangeError reports a number that does not fit the type of its field.
+type rangeError struct {
+	typ reflect.Type
+	err error
+}
+
+func (e *rangeError) Error() string {
+	// named types mention the kind that sets the limits, as in Port (uint16)
+	if e.typ.Name() != e.typ.Kind().String() {
+		return "value out of range for " + e.typ.String() + " (" + e.typ.Kind().String() + ")"
+	}
+	return "value out of range for " + e.typ.String()
+}
+
+func (e *rangeError) Unwrap() error {
+	return e.err
+}
+
+// negativeError reports a negative number for an unsigned field. It wraps
+// strconv.ErrRange since the value lies below the range of the type.
+type negativeError struct {
+	typ   reflect.Type
+	value string
+}
+
+func (e *negativeError) Error() string {
+	return fmt.Sprintf("negative value %q not allowed for unsigned field of type %s", e.value, e.typ)
+}
+
+func (e *negativeError) Unwrap() error {
+	return &strconv.NumError{Func: "ParseUint", Num: e.value, Err: strconv.ErrRange}
+}
+
+// numError tells an overflow apart from invalid syntax in the error of a
+// numeric conversion to typ.
+func numError(err error, typ reflect.Type) error {
+	if errors.Is(err, strconv.ErrRange) {
+		return &rangeError{typ: typ, err: err}
+	}
+	return err
+}
+
+// Errors is returned by ProcessAll and holds every error encountered while
+// processing a specification, in field order.
+type Errors []error
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Unwrap returns the individual errors so that errors.Is and errors.As can
+// match any of them.
+func (e Errors) Unwrap() []error {
+	return e
+}
+
+// Is reports whether any of the errors matches target. errors.Is only looks
+// at Unwrap() []error from Go 1.20 on, so older releases rely on this.
+func (e Errors) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first of the errors that matches target, like Is.
+func (e Errors) As(target interface{}) bool {
+	for _, err := range e {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// redacted replaces the value of secret fields in messages.
+const redacted = "***"
+
+// requiredNonEmpty is the value of the required tag for fields that must not
+// be empty rather than merely present.
+const requiredNonEmpty = "nonempty"
+
+// secretError stands in for the conversion error of a secret field. Messages
+// of conversion errors may quote the value, or a trimmed, decoded or split
+// part of it, so only the cause is kept: strconv.ErrSyntax, strconv.ErrRange
+// or ErrUnsupportedFieldType. Other errors are reduced to "invalid value".
+type secretError struct {
+	cause error
+}
+
+func newSecretError(err error) *secretError {
+	for _, cause := range []error{strconv.ErrRange, strconv.ErrSyntax, ErrUnsupportedFieldType} {
+		if errors.Is(err, cause) {
+			return &secretError{cause: cause}
+		}
+	}
+	return &secretError{}
+}
+
+func (e *secretError) Error() string {
+	if e.cause == nil {
+		return "invalid value"
+	}
+	return e.cause.Error()
+}
+
+func (e *secretError) Unwrap() error {
+	return e.cause
+}
+
+// varInfo maintains information about the configuration variable
+type varInfo struct {
+	Name  string
+	Alt   string
+	Key   string
+	Field reflect.Value
+	Tags  reflect.StructTag
+
+	index   []int
+	aliases []string // further keys tried after Key and Alt
+	cased   []string // keys as written, tried before their upper case form
+	pattern *regexp.Regexp
+
+	// root and sections are set for variables inside optional sections
+	root     reflect.Value
+	sections []*section
+
+	// elem is the struct type of the elements of a list of sections, which
+	// expandLists replaces by the variables of every element
+	elem reflect.Type
+
+	// shared names the field of the parent that reads the same key as this
+	// variable, which is promoted from the embedded struct named by embed
+	shared, embed string
+}
+
+// parseError reports that value could not be assigned to the variable.
+func (v varInfo) parseError(value string, err error) *ParseError {
+	if v.secret() {
+		err = newSecretError(err)
+		value = redacted
+	}
+	return &ParseError{
+		KeyName:   v.Key,
+		FieldName: v.Name,
+		TypeName:  v.Field.Type().String(),
+		Value:     value,
+		Err:       err,
+	}
+}
+
+// configured reports whether the variable is outside of optional sections
+// or inside configured ones.
+func (v varInfo) configured() bool {
+	for _, sec := range v.sections {
+		if !sec.configured {
+			return false
+		}
+	}
+	return true
+}
+
+// isSet reports whether any of the keys of the variable, or their file
+// variants, is set.
+func (v varInfo) isSet(lookup lookupFunc) (bool, error) {
+	keys := v.keys()
+	all := append([]string(nil), keys...)
+	for _, key := range keys {
+		all = append(all, key+fileSuffix)
+	}
+	_, ok, err := lookupKeys(all, lookup)
+	return ok, err
+}
+
+// keys returns the keys the variable is looked up by, in order.
+func (v varInfo) keys() []string {
+	keys := make([]string, 0, 2+len(v.aliases))
+	keys = append(keys, v.Key)
+	if v.Alt != "" {
+		keys = append(keys, v.Alt)
+	}
+	keys = append(keys, v.aliases...)
+	if len(v.cased) != len(keys) {
+		return keys
+	}
+	all := make([]string, 0, 2*len(keys))
+	for i, key := range keys {
+		if v.cased[i] != key {
+			all = append(all, v.cased[i])
+		}
+		all = append(all, key)
+	}
+	return all
+}
+
+// secret reports whether the value of the variable must be kept out of
+// error messages and usage output.
+func (v varInfo) secret() bool {
+	return isTrue(v.Tags.Get("secret"))
+}
+
+// description returns the human readable description of the variable, taken
+// from the desc tag or, if that is empty, the description tag.
+func (v varInfo) description() string {
+	if desc := v.Tags.Get("desc"); desc != "" {
+		return desc
+	}
+	return v.Tags.Get("description")
+}
+
+// specValue returns the struct that spec points to or ErrInvalidSpecification
+// if spec is not a non-nil struct pointer.
+func specValue(spec interface{}) (reflect.Value, error) {
+	s := reflect.ValueOf(spec)
+
+	if s.Kind() != reflect.Ptr {
+		return reflect.Value{}, ErrInvalidSpecification
+	}
+	s = s.Elem()
+	if s.Kind() != reflect.Struct {
+		return reflect.Value{}, ErrInvalidSpecification
+	}
+	return s, nil
+}
+
+// GatherInfo gathers information about the specified struct
+func gatherInfo(prefix string, spec interface{}) ([]varInfo, error) {
+	return (&Processor{}).gatherInfo(prefix, spec)
+}
+
+// gatherInfo gathers information about the specified struct using the
+// options of p.
+func (p *Processor) gatherInfo(prefix string, spec interface{}) ([]varInfo, error) {
+	s, err := specValue(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	infos, err := p.gatherType(p.specPrefix(s.Type(), prefix), s.Type(), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	bindInfos(s, infos)
+	return infos, nil
+}
+
+// specPrefix returns the prefix declared by the struct type t in the tag of a
+// blank field, as in
+//
+//	_ struct{} `envconfig:"prefix=MYAPP"`
+//
+// or prefix if t declares none.
+func (p *Processor) specPrefix(t reflect.Type, prefix string) string {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Name != "_" {
+			continue
+		}
+		// a blank field has no name, its tag holds options only
+		opts := tagOptions(f.Tag.Get(p.tagName()))
+		if declared := opts.tags(f.Tag).Get("prefix"); declared != "" {
+			return declared
+		}
+	}
+	return prefix
+}
+
+// gatherType gathers information about the fields of the struct type t. The
+// index of every variable is prefixed with index, the position of t within
+// the specification. within holds the struct types t is gathered inside of.
+// The returned variables are not bound to a field yet.
+func (p *Processor) gatherType(prefix string, t reflect.Type, index []int, within []reflect.Type) ([]varInfo, error) {
+	// a blank prefix is no prefix, keys never start with a separator
+	prefix = strings.TrimSpace(prefix)
+
+	// over allocate an info array, we will extend if needed later
+	infos := make([]varInfo, 0, t.NumField())
+	// keys promoted from embedded structs, by the name of the embedding field
+	promoted := make(map[string]string)
+	// the positions of the infos promoted from embedded structs, which may
+	// share the key of a field of t
+	embedded := make(map[int]bool)
+	for i := 0; i < t.NumField(); i++ {
+		ftype := t.Field(i)
+		name, opts := parseTag(ftype.Tag.Get(p.tagName()))
+		tags := opts.tags(ftype.Tag)
+		// like encoding/json, a tag of "-" skips the field entirely. Unexported
+		// fields cannot be set and are skipped before any struct among them is
+		// descended into, embedded ones included.
+		if ftype.PkgPath != "" || isTrue(tags.Get("ignored")) || ftype.Tag.Get(p.tagName()) == "-" {
+			continue
+		}
+
+		// pointers to structs are followed, other pointers are left to
+		// processField
+		typ := ftype.Type
+		for typ.Kind() == reflect.Ptr && typ.Elem().Kind() == reflect.Struct {
+			typ = typ.Elem()
+		}
+
+		// Capture information about the config variable
+		info := varInfo{
+			Name:  ftype.Name,
+			Tags:  tags,
+			Alt:   strings.ToUpper(name),
+			index: append(append([]int(nil), index...), i),
+		}
+
+		// Default to the field name as the env var name (will be upcased)
+		info.Key = info.Name
+		// the key as written, for processors that preserve case
+		var cased []string
+
+		// Best effort to un-pick camel casing as separate words
+		if isTrue(tags.Get("split_words")) {
+			words := gatherRegexp.FindAllStringSubmatch(ftype.Name, -1)
+			if len(words) > 0 {
+				var name []string
+				for _, words := range words {
+					if m := acronymRegexp.FindStringSubmatch(words[0]); len(m) == 3 {
+						name = append(name, m[1], m[2])
+					} else {
+						name = append(name, words[0])
+					}
+				}
+
+				info.Key = strings.Join(name, "_")
+			}
+		}
+		if info.Alt != "" {
+			info.Key = name
+		}
+		// exact keys are looked up verbatim and never fall back to the alt name
+		exact := opts.Contains("exact")
+		if exact {
+			if name != "" {
+				info.Key = name
+			}
+			info.Alt = ""
+		}
+		// noprefix keys are absolute, so there is nothing to fall back to
+		if opts.Contains("noprefix") {
+			info.Alt = ""
+		} else if prefix != "" {
+			info.Key = prefix + p.separator() + info.Key
+		}
+		cased = append(cased, info.Key)
+		if !exact {
+			info.Key = strings.ToUpper(info.Key)
+		}
+		// a key function takes over, the key is used as it returns it
+		if p.KeyFunc != nil {
+			info.Key = p.KeyFunc(prefix, ftype.Name, ftype.Tag)
+			cased[0] = info.Key
+		}
+		// the fallback to the bare name can be turned off, keys then always
+		// carry the prefix
+		noAlt := p.DisableAltFallback || opts.Contains("noalt")
+		if noAlt {
+			info.Alt = ""
+		}
+		if info.Alt != "" {
+			cased = append(cased, name)
+		}
+		// aliases are looked up like the name, after it
+		for _, alias := range strings.Split(tags.Get("aliases"), ",") {
+			alias = strings.TrimSpace(alias)
+			if alias == "" {
+				continue
+			}
+			key := alias
+			if !opts.Contains("noprefix") && prefix != "" {
+				key = prefix + p.separator() + alias
+			}
+			if exact {
+				info.aliases = append(info.aliases, key)
+				cased = append(cased, key)
+				continue
+			}
+			info.aliases = append(info.aliases, strings.ToUpper(key))
+			cased = append(cased, key)
+			if key != alias && !noAlt {
+				info.aliases = append(info.aliases, strings.ToUpper(alias))
+				cased = append(cased, alias)
+			}
+		}
+		if p.PreserveCase {
+			info.cased = cased
+		}
+		if err := prepareInfo(&info); err != nil {
+			return nil, err
+		}
+
+		// slices of structs are read from indexed keys, one section per
+		// element
+		if p.isList(ftype.Type, tags) {
+			info.elem = ftype.Type.Elem()
+			for info.elem.Kind() == reflect.Ptr {
+				info.elem = info.elem.Elem()
+			}
+			// element variables are gathered per index, make sure they can
+			// be. A list of a type that is already being gathered, such as
+			// the children of a tree, is checked there.
+			if !containsType(within, info.elem) && info.elem != t {
+				if _, err := p.gatherType(info.Key, info.elem, nil, append(within, t)); err != nil {
+					return nil, err
+				}
+			}
+			infos = append(infos, info)
+			continue
+		}
+
+		if p.isSection(ftype.Type, typ, tags) {
+			// embedded structs are flattened like promoted fields unless
+			// they are given a name
+			innerPrefix := prefix
+			flatten := ftype.Anonymous && name == ""
+			if !flatten {
+				innerPrefix = info.Key
+				if p.PreserveCase {
+					innerPrefix = cased[0]
+				}
+			}
+
+			embeddedInfos, err := p.gatherType(innerPrefix, typ, info.index, append(within, t))
+			if err != nil {
+				return nil, err
+			}
+			if flatten {
+				for _, e := range embeddedInfos {
+					if other, ok := promoted[e.Key]; ok && other != ftype.Name {
+						return nil, fmt.Errorf("envconfig: %s is defined by both %s and %s", e.Key, other, ftype.Name)
+					}
+					promoted[e.Key] = ftype.Name
+					embedded[len(infos)] = true
+					infos = append(infos, e)
+				}
+				continue
+			}
+			infos = append(infos, embeddedInfos...)
+
+			continue
+		}
+		infos = append(infos, info)
+	}
+
+	// like Go, a field of t shadows a promoted field of the same name, but
+	// both read the key
+	if len(embedded) > 0 {
+		own := make(map[string]string)
+		for i, info := range infos {
+			if !embedded[i] {
+				own[info.Key] = info.Name
+			}
+		}
+		for i := range embedded {
+			if name, ok := own[infos[i].Key]; ok {
+				infos[i].shared, infos[i].embed = name, t.Field(infos[i].index[len(index)]).Name
+			}
+		}
+	}
+	return infos, nil
+}
+
+// prepareInfo validates the tags of info that do not depend on a value and
+// compiles its pattern, if any.
+func prepareInfo(info *varInfo) error {
+	if _, err := intBase(info.Tags); err != nil {
+		return fmt.Errorf("envconfig: invalid base for %s: %v", info.Name, err)
+	}
+
+	pattern := info.Tags.Get("pattern")
+	if pattern == "" {
+		return nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("envconfig: invalid pattern for %s: %v", info.Name, err)
+	}
+	info.pattern = re
+	return nil
+}
+
+// isSection reports whether the field of type ft, which is typ once pointers
+// to structs are followed, is a struct whose fields are processed one by one.
+// Structs that decode themselves, or are decoded by the package as a whole,
+// hold a single value.
+func (p *Processor) isSection(ft, typ reflect.Type, tags reflect.StructTag) bool {
+	if typ.Kind() != reflect.Struct || typ == ipNetType || typ == bigIntType || typ == bigFloatType {
+		return false
+	}
+	// honor Decode if present, JSON values are unmarshaled as a whole
+	return !implementsInterface(typ) && !p.hasDecoder(ft, typ) && tags.Get("encoding") != "json"
+}
+
+// isList reports whether the field of type ft is a slice of sections.
+func (p *Processor) isList(ft reflect.Type, tags reflect.StructTag) bool {
+	if ft.Kind() != reflect.Slice || p.hasDecoder(ft) {
+		return false
+	}
+	et := ft.Elem()
+	typ := et
+	for typ.Kind() == reflect.Ptr && typ.Elem().Kind() == reflect.Struct {
+		typ = typ.Elem()
+	}
+	return (et == typ || et.Elem() == typ) && p.isSection(et, typ, tags)
+}
+
+// bindInfos sets the Field of every variable to its field in the struct s.
+// Pointers that are already set are followed, nil ones are left to
+// processField.
+//
+// Variables inside a nil pointer to a struct, an optional section, are bound
+// to a placeholder instead. The section is only allocated once a value is
+// assigned to one of its variables.
+func bindInfos(s reflect.Value, infos []varInfo) {
+	sections := make(map[string]*section)
+	for i := range infos {
+		info := &infos[i]
+		info.root, info.sections = reflect.Value{}, nil
+
+		v := s
+		for n, j := range info.index {
+			for v.Kind() == reflect.Ptr && !v.IsNil() {
+				v = v.Elem()
+			}
+			if v.Kind() == reflect.Ptr {
+				// a nil section, every enclosing one is tracked
+				path := fmt.Sprint(info.index[:n])
+				sec, ok := sections[path]
+				if !ok {
+					sec = &section{}
+					sections[path] = sec
+				}
+				info.sections = append(info.sections, sec)
+				info.root = s
+				v = reflect.New(v.Type().Elem()).Elem()
+			}
+			v = v.Field(j)
+		}
+		for v.Kind() == reflect.Ptr && !v.IsNil() {
+			v = v.Elem()
+		}
+		info.Field = v
+	}
+}
+
+// section tracks whether an optional section of a specification is
+// configured, that is, whether any of its variables is set.
+type section struct {
+	configured bool
+}
+
+// detectSections marks the optional sections that are configured.
+func detectSections(infos []varInfo, lookup lookupFunc) error {
+	for _, info := range infos {
+		if len(info.sections) == 0 || info.configured() {
+			continue
+		}
+		set, err := info.isSet(lookup)
+		if err != nil {
+			return err
+		}
+		if set {
+			for _, sec := range info.sections {
+				sec.configured = true
+			}
+		}
+	}
+	return nil
+}
+
+// expandLists replaces every list of sections in infos by the variables of
+// its elements, bound to a newly allocated slice. Element n is read from keys
+// below the key of the list followed by n, so MYAPP_SERVERS_0_HOST is the Host
+// of the first element of Servers. Elements are counted up from 0 and the list
+// ends at the first index none of whose variables is set. Lists without
+// elements leave their field untouched.
+func (p *Processor) expandLists(infos []varInfo, lookup lookupFunc) ([]varInfo, error) {
+	var expanded []varInfo
+	for i, info := range infos {
+		if info.elem == nil {
+			if expanded != nil {
+				expanded = append(expanded, info)
+			}
+			continue
+		}
+		if expanded == nil {
+			expanded = append([]varInfo(nil), infos[:i]...)
+		}
+
+		var elems [][]varInfo
+		for n := 0; ; n++ {
+			elemInfos, err := p.gatherElem(info, strconv.Itoa(n))
+			if err != nil {
+				return nil, err
+			}
+			set, err := p.elemSet(elemInfos, p.elemPrefix(info, strconv.Itoa(n)), lookup, []reflect.Type{info.elem})
+			if err != nil {
+				return nil, err
+			}
+			if !set {
+				break
+			}
+			elems = append(elems, elemInfos)
+		}
+		if len(elems) == 0 {
+			continue
+		}
+
+		// elements configure the optional sections the list is in
+		field := info.Field
+		if info.root.IsValid() {
+			field = fieldByIndex(info.root, info.index)
+			for _, sec := range info.sections {
+				sec.configured = true
+			}
+		}
+		list := reflect.MakeSlice(field.Type(), len(elems), len(elems))
+		for n, elemInfos := range elems {
+			v := list.Index(n)
+			if v.Kind() == reflect.Ptr {
+				v.Set(reflect.New(info.elem))
+				v = v.Elem()
+			}
+			bindInfos(v, elemInfos)
+			elemInfos, err := p.expandLists(elemInfos, lookup)
+			if err != nil {
+				return nil, err
+			}
+			expanded = append(expanded, elemInfos...)
+		}
+		field.Set(list)
+	}
+	if expanded == nil {
+		return infos, nil
+	}
+	return expanded, nil
+}
+
+// elemPrefix returns the prefix of the keys of element n of the list info.
+func (p *Processor) elemPrefix(info varInfo, n string) string {
+	return info.Key + p.separator() + n
+}
+
+// gatherElem gathers the unbound variables of element n of the list info.
+// Elements never fall back to bare names, which would be shared by all of
+// them.
+func (p *Processor) gatherElem(info varInfo, n string) ([]varInfo, error) {
+	q := &Processor{
+		Separator:          p.Separator,
+		DisableAltFallback: true,
+		PreserveCase:       p.PreserveCase,
+		TagName:            p.TagName,
+		KeyFunc:            p.KeyFunc,
+		decoders:           p.decoders,
+	}
+	return q.gatherType(p.elemPrefix(info, n), info.elem, nil, nil)
+}
+
+// elemSet reports whether any variable of an element is set by a key below
+// prefix, the prefix of the element. Keys outside of it, such as noprefix
+// keys, are the same for every element and do not count. Nested lists count
+// through their first element, unless its type is one of within, the element
+// types being checked, which would never end for a list of its own type.
+func (p *Processor) elemSet(infos []varInfo, prefix string, lookup lookupFunc, within []reflect.Type) (bool, error) {
+	prefix += p.separator()
+	for _, info := range infos {
+		if info.elem != nil {
+			if containsType(within, info.elem) {
+				continue
+			}
+			first, err := p.gatherElem(info, "0")
+			if err != nil {
+				return false, err
+			}
+			set, err := p.elemSet(first, p.elemPrefix(info, "0"), lookup, append(within, info.elem))
+			if err != nil || set {
+				return set, err
+			}
+			continue
+		}
+		for _, key := range info.keys() {
+			if !strings.HasPrefix(key, prefix) {
+				continue
+			}
+			_, ok, err := lookupKeys([]string{key, key + fileSuffix}, lookup)
+			if err != nil || ok {
+				return ok, err
+			}
+		}
+	}
+	return false, nil
+}
+
+// fieldByIndex returns the nested field of the struct v at index. Nil
+// pointers to structs are allocated on the way.
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for _, i := range index {
+		for v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	return v
+}
+
+// tagOptions is the comma-separated list of options that may follow the name
+// in an envconfig struct tag.
+type tagOptions string
+
+// parseTag splits an envconfig struct tag into its name and options.
+func parseTag(tag string) (string, tagOptions) {
+	if i := strings.Index(tag, ","); i >= 0 {
+		return tag[:i], tagOptions(tag[i+1:])
+	}
+	return tag, ""
+}
+
+// Contains reports whether the option name is present in the list.
+func (o tagOptions) Contains(name string) bool {
+	if o == "" {
+		return false
+	}
+	for _, opt := range strings.Split(string(o), ",") {
+		if strings.TrimSpace(opt) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// tags returns the struct tag of a field with the options written as tags in
+// front, so that they take precedence over separate tags of the same name. An
+// option of the form key=value becomes key:"value" and any other option, such
+// as required, becomes key:"true". Values cannot contain commas.
+func (o tagOptions) tags(tag reflect.StructTag) reflect.StructTag {
+	if o == "" {
+		return tag
+	}
+	var b strings.Builder
+	for _, opt := range strings.Split(string(o), ",") {
+		opt = strings.TrimSpace(opt)
+		if opt == "" {
+			continue
+		}
+		key, value := opt, "true"
+		if i := strings.Index(opt, "="); i >= 0 {
+			key, value = strings.TrimSpace(opt[:i]), opt[i+1:]
+		}
+		fmt.Fprintf(&b, "%s:%s ", key, strconv.Quote(value))
+	}
+	return reflect.StructTag(b.String() + string(tag))
+}
+
+// CheckDisallowed checks that no environment variables with the prefix are set
+// that we don't know how or want to parse. This is likely only meaningful with
+// a non-empty prefix. All offending variables are listed in the error.
+func CheckDisallowed(prefix string, spec interface{}) error {
+	p := &Processor{}
+	infos, err := p.gatherInfo(prefix, spec)
+	if err != nil {
+		return err
+	}
+	if infos, err = p.expandLists(infos, plainLookup(envLookup)); err != nil {
+		return err
+	}
+
+	unknown := p.unknownVars(prefix, spec, infos)
+	switch len(unknown) {
+	case 0:
+		return nil
+	case 1:
+		return fmt.Errorf("unknown environment variable %s", unknown[0])
+	default:
+		return fmt.Errorf("unknown environment variables %s", strings.Join(unknown, ", "))
+	}
+}
+
+// unknownVars returns the sorted names of the environment variables with the
+// prefix of spec, or the SecondaryPrefix, that none of infos is read from.
+// Only variables of the process environment can be listed, so with a lookup
+// swapped by SetDefaultLookup those it does not hold are left out.
+func (p *Processor) unknownVars(prefix string, spec interface{}, infos []varInfo) []string {
+	// keys are looked up in either case with PreserveCase, so names are
+	// compared regardless of case
+	norm := func(name string) string {
+		if p.PreserveCase {
+			return strings.ToUpper(name)
+		}
+		return name
+	}
+	vars := make(map[string]struct{})
+	for _, info := range infos {
+		for _, key := range info.keys() {
+			vars[norm(key)] = struct{}{}
+			vars[norm(key+fileSuffix)] = struct{}{}
+		}
+	}
+
+	if s, err := specValue(spec); err == nil {
+		prefix = p.specPrefix(s.Type(), prefix)
+	}
+	if prefix = strings.TrimSpace(prefix); prefix != "" {
+		prefix = strings.ToUpper(prefix + p.separator())
+	}
+	// variables with the secondary prefix are known if the field reads the
+	// same name with the primary one
+	secondary := strings.TrimSpace(p.SecondaryPrefix)
+	if secondary != "" {
+		secondary = strings.ToUpper(secondary + p.separator())
+	}
+
+	var unknown []string
+	for _, env := range os.Environ() {
+		v := strings.SplitN(env, "=", 2)[0]
+		name := norm(v)
+		switch {
+		case strings.HasPrefix(name, prefix):
+		case secondary != "" && strings.HasPrefix(name, secondary):
+			name = prefix + name[len(secondary):]
+		default:
+			continue
+		}
+		if _, ok := envLookup(v); !ok {
+			continue
+		}
+		if _, found := vars[name]; !found {
+			unknown = append(unknown, v)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+// Process populates the specified struct based on environment variables
+func Process(prefix string, spec interface{}) error {
+	// `os.Getenv` cannot differentiate between an explicitly set empty value
+	// and an unset value. `os.LookupEnv` is preferred to `syscall.Getenv`,
+	// but it is only available in go1.5 or newer. We're using Go build tags
+	// here to use os.LookupEnv for >=go1.5
+	return ProcessWith(prefix, spec, envLookup)
+}
+
+var (
+	lookupMu      sync.RWMutex
+	defaultLookup = lookupEnv
+)
+
+// SetDefaultLookup makes Process, and the other functions that look values up
+// in the process environment, read them through lookup instead until the
+// returned restore function is called. A nil lookup stands for the
+// environment. Checks for unknown variables, as done by CheckDisallowed,
+// ProcessWithReport and a Processor in strict mode, still list the variables
+// of the process environment, since a lookup cannot be enumerated, but leave
+// out those that lookup does not hold. It is meant for tests that cannot pass
+// a lookup to ProcessWith; the swap is global, so tests that use it must not
+// run in parallel.
+func SetDefaultLookup(lookup func(key string) (string, bool)) (restore func()) {
+	if lookup == nil {
+		lookup = lookupEnv
+	}
+	lookupMu.Lock()
+	prev := defaultLookup
+	defaultLookup = lookup
+	lookupMu.Unlock()
+	return func() {
+		lookupMu.Lock()
+		defaultLookup = prev
+		lookupMu.Unlock()
+	}
+}
+
+// envLookup looks key up through the default lookup.
+func envLookup(key string) (string, bool) {
+	lookupMu.RLock()
+	lookup := defaultLookup
+	lookupMu.RUnlock()
+	return lookup(key)
+}
+
+// ProcessWith is the same as Process but reads values through lookup instead
+// of the process environment. The lookup function has the same semantics as
+// os.LookupEnv, which makes it easy to substitute a map in tests or another
+// source of configuration altogether. A nil lookup reads from the environment.
+func ProcessWith(prefix string, spec interface{}, lookup func(key string) (string, bool)) error {
+	if lookup == nil {
+		lookup = envLookup
+	}
+	p := &Processor{}
+	infos, err := p.gatherInfo(prefix, spec)
+	if err != nil {
+		return err
+	}
+	if infos, err = p.expandLists(infos, plainLookup(lookup)); err != nil {
+		return err
+	}
+	if err := detectSections(infos, plainLookup(lookup)); err != nil {
+		return err
+	}
+
+	for _, info := range infos {
+		if err := p.processInfo(info, plainLookup(lookup)); err != nil {
+			return err
+		}
+	}
+
+	return p.postProcess(spec, infos, plainLookup(lookup))
+}
+
+// ProcessPrefixes is the same as Process but tries several prefixes in
+// order, so that environment specific variables such as PROD_PORT can be
+// layered over a base such as DEFAULT_PORT. Every key is looked up with the
+// first prefix and then with each following one until a value is found. Only
+// then do the keys without a prefix, defaults and required checks apply, and
+// errors name the keys of the first prefix. A prefix declared by the
+// specification replaces the first one, as with Process, and the following
+// prefixes are still tried after it.
+func ProcessPrefixes(prefixes []string, spec interface{}) error {
+	if len(prefixes) == 0 {
+		return Process("", spec)
+	}
+	p := &Processor{}
+	keyPrefix := func(prefix string) string {
+		if prefix = strings.TrimSpace(prefix); prefix == "" {
+			return ""
+		}
+		return strings.ToUpper(prefix) + p.separator()
+	}
+	first := prefixes[0]
+	// an invalid specification is reported by ProcessWith
+	if s, err := specValue(spec); err == nil {
+		first = p.specPrefix(s.Type(), first)
+	}
+	others := make([]string, len(prefixes)-1)
+	for i, prefix := range prefixes[1:] {
+		others[i] = keyPrefix(prefix)
+	}
+	return ProcessWith(prefixes[0], spec, layeredLookup(keyPrefix(first), others, envLookup))
+}
+
+// layeredLookup returns a lookup that tries a key starting with first as is
+// and then with first replaced by each of others in turn. The others are
+// upper cased along with keys whose first prefix is.
+func layeredLookup(first string, others []string, lookup func(key string) (string, bool)) func(key string) (string, bool) {
+	return func(key string) (string, bool) {
+		value, ok := lookup(key)
+		if ok || len(key) < len(first) || !strings.EqualFold(key[:len(first)], first) {
+			return value, ok
+		}
+		upper := key[:len(first)] != first
+		name := key[len(first):]
+		for _, prefix := range others {
+			if upper {
+				prefix = strings.ToUpper(prefix)
+			}
+			if value, ok := lookup(prefix + name); ok {
+				return value, true
+			}
+		}
+		return "", false
+	}
+}
+
+// ProcessWithDefaults is the same as Process but first sets spec to a copy of
+// defaults, a pointer to a struct of the same type. Fields whose variables are
+// not set keep the value of defaults, which allows defaults of any type rather
+// than strings in tags. Pointers are copied deeply so that processing never
+// modifies defaults. Default tags still apply to variables that are not set.
+func ProcessWithDefaults(prefix string, spec interface{}, defaults interface{}) error {
+	s, err := specValue(spec)
+	if err != nil {
+		return err
+	}
+	d, err := specValue(defaults)
+	if err != nil {
+		return err
+	}
+	if s.Type() != d.Type() {
+		return fmt.Errorf("envconfig: defaults of type %s cannot be used for %s", d.Type(), s.Type())
+	}
+	copyValue(s, d)
+	return Process(prefix, spec)
+}
+
+// Reload processes the environment into a copy of spec and, if that
+// succeeds, updates spec and returns the names of the fields whose value
+// changed, in the order they are declared. Fields of nested structs are named
+// by their path, such as DB.Host. On error spec is left untouched.
+//
+// Like Process, Reload leaves fields whose variables are not set alone unless
+// they have a default.
+func Reload(prefix string, spec interface{}) ([]string, error) {
+	s, err := specValue(spec)
+	if err != nil {
+		return nil, err
+	}
+	next := reflect.New(s.Type())
+	copyValue(next.Elem(), s)
+	if err := Process(prefix, next.Interface()); err != nil {
+		return nil, err
+	}
+
+	changed := (&Processor{}).diff(nil, s, next.Elem())
+	s.Set(next.Elem())
+	return changed, nil
+}
+
+// diff returns the paths of the fields that differ between the structs a and
+// b, below path. Nested structs are compared field by field.
+func (p *Processor) diff(path []string, a, b reflect.Value) []string {
+	var changed []string
+	for i := 0; i < a.NumField(); i++ {
+		f := a.Type().Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		fa, fb := a.Field(i), b.Field(i)
+		name := append(append([]string(nil), path...), f.Name)
+
+		typ := f.Type
+		for typ.Kind() == reflect.Ptr && typ.Elem().Kind() == reflect.Struct {
+			typ = typ.Elem()
+		}
+		if p.isSection(f.Type, typ, f.Tag) {
+			for fa.Kind() == reflect.Ptr && fb.Kind() == reflect.Ptr && !fa.IsNil() && !fb.IsNil() {
+				fa, fb = fa.Elem(), fb.Elem()
+			}
+			if fa.Kind() == reflect.Struct {
+				changed = append(changed, p.diff(name, fa, fb)...)
+				continue
+			}
+		}
+		if !reflect.DeepEqual(fa.Interface(), fb.Interface()) {
+			changed = append(changed, strings.Join(name, "."))
+		}
+	}
+	return changed
+}
+
+// copyValue sets dst to src, giving dst its own copy of everything src points
+// to.
+func copyValue(dst, src reflect.Value) {
+	dst.Set(src)
+	switch src.Kind() {
+	case reflect.Ptr:
+		if !src.IsNil() {
+			v := reflect.New(src.Type().Elem())
+			copyValue(v.Elem(), src.Elem())
+			dst.Set(v)
+		}
+	case reflect.Struct:
+		for i := 0; i < src.NumField(); i++ {
+			if dst.Field(i).CanSet() {
+				copyValue(dst.Field(i), src.Field(i))
+			}
+		}
+	}
+}
+
+// MultiSource combines several lookups into one. A key is looked up in each
+// source in turn and the first source that has it wins, so earlier sources
+// take precedence over later ones.
+func MultiSource(sources ...func(key string) (string, bool)) func(key string) (string, bool) {
+	return func(key string) (string, bool) {
+		for _, source := range sources {
+			if value, ok := source(key); ok {
+				return value, true
+			}
+		}
+		return "", false
+	}
+}
+
+// ProcessContext is the same as ProcessWith but for lookups that may block or
+// fail, such as calls to a secrets manager. Lookup errors abort processing and
+// are returned as is. If ctx is done before all fields are processed the
+// error of the context is returned. A nil lookup reads from the environment.
+func ProcessContext(ctx context.Context, prefix string, spec interface{}, lookup func(ctx context.Context, key string) (string, bool, error)) error {
+	if lookup == nil {
+		lookup = func(_ context.Context, key string) (string, bool, error) {
+			value, ok := envLookup(key)
+			return value, ok, nil
+		}
+	}
+	p := &Processor{}
+	infos, err := p.gatherInfo(prefix, spec)
+	if err != nil {
+		return err
+	}
+
+	// a single variable may take several lookups, check the context before
+	// every one of them
+	lookupCtx := func(key string) (string, bool, error) {
+		if err := ctx.Err(); err != nil {
+			return "", false, err
+		}
+		return lookup(ctx, key)
+	}
+	if infos, err = p.expandLists(infos, lookupCtx); err != nil {
+		return err
+	}
+	if err := detectSections(infos, lookupCtx); err != nil {
+		return err
+	}
+	for _, info := range infos {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		err := p.processInfo(info, lookupCtx)
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			return err
+		}
+	}
+
+	return p.postProcess(spec, infos, lookupCtx)
+}
+
+// ProcessAll is the same as Process but does not stop at the first error.
+// Every field is attempted and all problems are returned together as Errors.
+func ProcessAll(prefix string, spec interface{}) error {
+	p := &Processor{}
+	infos, err := p.gatherInfo(prefix, spec)
+	if err != nil {
+		return err
+	}
+
+	if infos, err = p.expandLists(infos, plainLookup(envLookup)); err != nil {
+		return err
+	}
+	if err := detectSections(infos, plainLookup(envLookup)); err != nil {
+		return err
+	}
+
+	var errs Errors
+	for _, info := range infos {
+		if err := p.processInfo(info, plainLookup(envLookup)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return p.postProcess(spec, infos, plainLookup(envLookup))
+}
+
+// SpecWithPrefix pairs a specification with the prefix it is read with, for
+// ProcessGroup.
+type SpecWithPrefix struct {
+	Prefix string
+	Spec   interface{}
+}
+
+// ProcessGroup processes every item like ProcessAll, so that a problem in one
+// specification does not hide those of the others. All errors are returned
+// together as Errors, in the order of the items.
+func ProcessGroup(items ...SpecWithPrefix) error {
+	var errs Errors
+	for _, item := range items {
+		err := ProcessAll(item.Prefix, item.Spec)
+		if all, ok := err.(Errors); ok {
+			errs = append(errs, all...)
+		} else if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Report is the outcome of ProcessWithReport. Errors holds the problems that
+// make the configuration unusable, Warnings those worth logging only.
+type Report struct {
+	Errors   []error
+	Warnings []string
+}
+
+// ProcessWithReport is the same as ProcessAll but also reports warnings. A
+// value read from one of the aliases of a field, which are meant for names
+// that have been replaced, a key read by both a field and the field it
+// shadows in an embedded struct, and variables with the prefix that no field
+// reads are warnings. Values that cannot be assigned, missing required keys and
+// errors of post processing are errors, and post processing only runs when
+// there are no others.
+func ProcessWithReport(prefix string, spec interface{}) Report {
+	var report Report
+	p := &Processor{}
+	infos, err := p.gatherInfo(prefix, spec)
+	if err == nil {
+		infos, err = p.expandLists(infos, plainLookup(envLookup))
+	}
+	if err == nil {
+		err = detectSections(infos, plainLookup(envLookup))
+	}
+	if err != nil {
+		report.Errors = append(report.Errors, err)
+		return report
+	}
+
+	for _, info := range infos {
+		if !info.configured() {
+			continue
+		}
+		r, ok, err := p.resolveInfo(info, plainLookup(envLookup))
+		if err == nil && ok {
+			err = p.assignInfo(info, r)
+		}
+		if err != nil {
+			report.Errors = append(report.Errors, err)
+			continue
+		}
+		for _, alias := range info.aliases {
+			if ok && r.key == alias {
+				report.Warnings = append(report.Warnings, fmt.Sprintf("%s is deprecated, use %s instead", alias, info.Key))
+				break
+			}
+		}
+	}
+	for _, info := range infos {
+		if info.shared != "" {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("%s is read by both %s and %s.%s", info.Key, info.shared, info.embed, info.Name))
+		}
+	}
+	for _, v := range p.unknownVars(prefix, spec, infos) {
+		report.Warnings = append(report.Warnings, "unknown environment variable "+v)
+	}
+
+	if len(report.Errors) == 0 {
+		if err := p.postProcess(spec, infos, plainLookup(envLookup)); err != nil {
+			report.Errors = append(report.Errors, err)
+		}
+	}
+	return report
+}
+
+// Resolve reports what Process would assign without touching spec. It returns
+// the value of every variable that is set or has a default, keyed by the
+// variable name, after alt keys and defaults have been applied. The values of
+// secret fields are masked. Use Sources to tell defaults apart from values
+// that were set.
+//
+// The values are also converted into a scratch copy of spec, so Resolve fails
+// on the same errors Process would.
+func Resolve(prefix string, spec interface{}) (map[string]string, error) {
+	resolved := make(map[string]string)
+	err := resolveSpec(prefix, spec, func(info varInfo, r resolution) {
+		if r.source == SourceUnset {
+			return
+		}
+		value := r.value
+		if info.secret() {
+			value = redacted
+		}
+		resolved[info.Key] = value
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resolved, nil
+}
+
+// Source tells where the value of a variable comes from.
+type Source int
+
+const (
+	// SourceUnset means the variable is not set and has no default.
+	SourceUnset Source = iota
+	// SourceEnv means the value was read from the environment, including
+	// values read from a file named by a _FILE variable.
+	SourceEnv
+	// SourceDefault means the value is the default of the field.
+	SourceDefault
+)
+
+func (s Source) String() string {
+	switch s {
+	case SourceUnset:
+		return "unset"
+	case SourceEnv:
+		return "env"
+	case SourceDefault:
+		return "default"
+	}
+	return "Source(" + strconv.Itoa(int(s)) + ")"
+}
+
+// Sources reports where Process would take the value of every variable from,
+// keyed by the variable name like Resolve. It is useful to detect critical
+// values falling back to their default. Like Resolve it does not touch spec.
+func Sources(prefix string, spec interface{}) (map[string]Source, error) {
+	sources := make(map[string]Source)
+	err := resolveSpec(prefix, spec, func(info varInfo, r resolution) {
+		sources[info.Key] = r.source
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sources, nil
+}
+
+// resolveSpec processes the environment into a scratch copy of spec and calls
+// visit with the resolution of every variable.
+func resolveSpec(prefix string, spec interface{}, visit func(info varInfo, r resolution)) error {
+	s, err := specValue(spec)
+	if err != nil {
+		return err
+	}
+
+	p := &Processor{}
+	infos, err := p.gatherType(p.specPrefix(s.Type(), prefix), s.Type(), nil, nil)
+	if err != nil {
+		return err
+	}
+	scratch := reflect.New(s.Type())
+	bindInfos(scratch.Elem(), infos)
+
+	if infos, err = p.expandLists(infos, plainLookup(envLookup)); err != nil {
+		return err
+	}
+	if err := detectSections(infos, plainLookup(envLookup)); err != nil {
+		return err
+	}
+	for _, info := range infos {
+		if !info.configured() {
+			visit(info, resolution{})
+			continue
+		}
+		r, ok, err := p.resolveInfo(info, plainLookup(envLookup))
+		if err != nil {
+			return err
+		}
+		if ok {
+			if err := p.assignInfo(info, r); err != nil {
+				return err
+			}
+		}
+		visit(info, r)
+	}
+	return p.postProcess(scratch.Interface(), infos, plainLookup(envLookup))
+}
+
+// postProcess runs the final pass once every variable has been assigned. It
+// checks the conditional requirements of required_if tags, then calls
+// PostProcess for every variable if spec implements PostProcessor and
+// Validate if spec implements Validator.
+func (p *Processor) postProcess(spec interface{}, infos []varInfo, lookup lookupFunc) error {
+	if err := p.checkRequiredIf(infos, lookup); err != nil {
+		return err
+	}
+	if pp, ok := spec.(PostProcessor); ok {
+		for _, info := range infos {
+			if err := pp.PostProcess(info.Name); err != nil {
+				return err
+			}
+		}
+	}
+	if v, ok := spec.(Validator); ok {
+		return v.Validate()
+	}
+	return nil
+}
+
+// checkRequiredIf reports a RequiredError for the first variable whose
+// required_if condition, of the form Field=value, holds while the variable is
+// not set. Field names a field of the same struct and value is converted to
+// its type for the comparison.
+func (p *Processor) checkRequiredIf(infos []varInfo, lookup lookupFunc) error {
+	for i, info := range infos {
+		cond := info.Tags.Get("required_if")
+		if cond == "" || !info.configured() {
+			continue
+		}
+		eq := strings.Index(cond, "=")
+		if eq < 0 {
+			return fmt.Errorf("envconfig: invalid required_if condition %q for %s", cond, info.Name)
+		}
+		name, want := strings.TrimSpace(cond[:eq]), cond[eq+1:]
+
+		ref, ok := siblingInfo(infos, i, name)
+		if !ok {
+			return fmt.Errorf("envconfig: required_if of %s refers to unknown field %s", info.Name, name)
+		}
+		field := ref.Field
+		if ref.root.IsValid() && ref.configured() {
+			field = fieldByIndex(ref.root, ref.index)
+			for field.Kind() == reflect.Ptr && !field.IsNil() {
+				field = field.Elem()
+			}
+		}
+		target := reflect.New(field.Type()).Elem()
+		if err := p.processField(want, target, ref.Tags); err != nil {
+			return fmt.Errorf("envconfig: invalid required_if condition %q for %s: %v", cond, info.Name, err)
+		}
+		if !reflect.DeepEqual(target.Interface(), field.Interface()) {
+			continue
+		}
+
+		_, set, err := p.resolveInfo(info, lookup)
+		if err != nil {
+			return err
+		}
+		if !set {
+			return info.requiredError()
+		}
+	}
+	return nil
+}
+
+// siblingInfo returns the variable of the field called name in the same
+// struct as infos[i]. Elements of lists share their index, so the nearest
+// variable wins.
+func siblingInfo(infos []varInfo, i int, name string) (varInfo, bool) {
+	parent := infos[i].index[:len(infos[i].index)-1]
+	sibling := func(j int) bool {
+		if j < 0 || j >= len(infos) || infos[j].Name != name {
+			return false
+		}
+		index := infos[j].index
+		return len(index) == len(parent)+1 && reflect.DeepEqual(index[:len(parent)], parent)
+	}
+	for d := 1; d < len(infos); d++ {
+		if sibling(i - d) {
+			return infos[i-d], true
+		}
+		if sibling(i + d) {
+			return infos[i+d], true
+		}
+	}
+	return varInfo{}, false
+}
+
+// lookupFunc is the internal form of a lookup, which may fail.
+type lookupFunc func(key string) (string, bool, error)
+
+// plainLookup adapts a lookup with the semantics of os.LookupEnv.
+func plainLookup(lookup func(string) (string, bool)) lookupFunc {
+	return func(key string) (string, bool, error) {
+		value, ok := lookup(key)
+		return value, ok, nil
+	}
+}
+
+// processInfo looks up the value for a single configuration variable and
+// assigns it to its field.
+func (p *Processor) processInfo(info varInfo, lookup lookupFunc) error {
+	if !info.configured() {
+		p.onResolve(info, resolution{})
+		return nil
+	}
+	r, ok, err := p.resolveInfo(info, lookup)
+	if err != nil {
+		return err
+	}
+	if ok {
+		if err := p.assignInfo(info, r); err != nil {
+			return err
+		}
+	}
+	p.onResolve(info, r)
+	return nil
+}
+
+// onResolve reports the resolution of a variable to the OnResolve hook, if
+// any.
+func (p *Processor) onResolve(info varInfo, r resolution) {
+	if p.OnResolve == nil {
+		return
+	}
+	key := r.key
+	if key == "" {
+		key = info.Key
+	}
+	p.OnResolve(key, info.Name, r.source.String(), info.secret())
+}
+
+// resolution is the value found for a configuration variable.
+type resolution struct {
+	value  string
+	key    string // the key that supplied value
+	source Source
+}
+
+// resolveInfo looks up the value for a single configuration variable, falling
+// back to the alt key, a file, a template and the default. It reports false if
+// there is nothing to assign, and an error if a required variable is missing.
+func (p *Processor) resolveInfo(info varInfo, lookup lookupFunc) (resolution, bool, error) {
+	r, ok, err := lookupKeys(info.keys(), lookup)
+	if err != nil {
+		return resolution{}, false, err
+	}
+	if !ok {
+		r, ok, err = lookupFile(info, lookup)
+		if err != nil {
+			return resolution{}, false, err
+		}
+	}
+	if tmpl := info.Tags.Get("template"); !ok && tmpl != "" {
+		r, ok, err = lookupTemplate(info, tmpl, lookup)
+		if err != nil {
+			return resolution{}, false, err
+		}
+	}
+
+	// an explicitly empty value wins over the default unless the field asks
+	// otherwise. A nonempty requirement treats it as unset too, so the
+	// default applies and the required check fails without one.
+	req := info.Tags.Get("required")
+	nonEmpty := req == requiredNonEmpty
+	def, hasDef := info.Tags.Lookup("default")
+	// an empty default only means something to slices and maps, which it
+	// leaves empty rather than nil
+	if def == "" && !isCollection(info.Field.Type()) {
+		hasDef = false
+	}
+	if ok && r.value == "" && (nonEmpty || def != "" && isTrue(info.Tags.Get("default_on_empty"))) {
+		ok = false
+	}
+
+	if hasDef && !ok {
+		value, err := defaultValue(info, def, lookup)
+		if err != nil {
+			return resolution{}, false, err
+		}
+		return resolution{value: value, key: info.Key, source: SourceDefault}, true, nil
+	}
+
+	if !ok {
+		if nonEmpty || isTrue(req) {
+			return resolution{}, false, info.requiredError()
+		}
+		return resolution{}, false, nil
+	}
+
+	// values may refer to other variables like defaults do, if asked to
+	if isTrue(info.Tags.Get("expand")) {
+		if r.value, err = info.expand(r.value, lookup, "value of "+r.key); err != nil {
+			return resolution{}, false, err
+		}
+	}
+	return r, true, nil
+}
+
+// requiredError reports that the variable of info is missing.
+func (info varInfo) requiredError() error {
+	key := info.Key
+	if info.Alt != "" {
+		key = info.Alt
+	}
+	return &RequiredError{
+		KeyName:     key,
+		FieldName:   info.Name,
+		aliases:     info.aliases,
+		description: info.description(),
+	}
+}
+
+// isCollection reports whether t, once pointers are followed, is a slice or a
+// map.
+func isCollection(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Slice || t.Kind() == reflect.Map
 }
 
-// Decoder has the same semantics as Setter, but takes higher precedence.
-// It is provided for historical compatibility.
-type Decoder interface {
-	Decode(value string) error
+// containsType reports whether t is one of types.
+func containsType(types []reflect.Type, t reflect.Type) bool {
+	for _, typ := range types {
+		if typ == t {
+			return true
+		}
+	}
+	return false
 }
 
-// Setter is implemented by types can self-deserialize values.
-// Any type that implements flag.Value also implements Setter.
-type Setter interface {
-	Set(value string) error
+// lookupKeys returns the value of the first of keys that is set.
+func lookupKeys(keys []string, lookup lookupFunc) (resolution, bool, error) {
+	for _, key := range keys {
+		value, ok, err := lookup(key)
+		if err != nil {
+			return resolution{}, false, err
+		}
+		if ok {
+			return resolution{value: value, key: key, source: SourceEnv}, true, nil
+		}
+	}
+	return resolution{}, false, nil
 }
 
-func (e *ParseError) Error() string {
-	return fmt.Sprintf("envconfig.Process: assigning %[1]s to %[2]s: converting '%[3]s' to type %[4]s. details: %[5]s", e.KeyName, e.FieldName, e.Value, e.TypeName, e.Err)
+// fileSuffix marks a variable that holds the path of a file with the value,
+// the way Docker and Kubernetes expose secrets.
+const fileSuffix = "_FILE"
+
+// lookupFile reads the value of info from the file named by one of its keys
+// with fileSuffix appended. The contents are trimmed of surrounding white
+// space.
+func lookupFile(info varInfo, lookup lookupFunc) (resolution, bool, error) {
+	keys := info.keys()
+	for i := range keys {
+		keys[i] += fileSuffix
+	}
+	r, ok, err := lookupKeys(keys, lookup)
+	if err != nil || !ok {
+		return resolution{}, false, err
+	}
+	b, err := ioutil.ReadFile(r.value)
+	if err != nil {
+		return resolution{}, false, fmt.Errorf("envconfig: reading %s: %v", r.key, err)
+	}
+	r.value = strings.TrimSpace(string(b))
+	return r, true, nil
 }
 
-// varInfo maintains information about the configuration variable
-type varInfo struct {
-	Name  string
-	Alt   string
-	Key   string
-	Field reflect.Value
-	Tags  reflect.StructTag
+// defaultFuncPrefix marks a default computed by a registered function.
+const defaultFuncPrefix = "$fn:"
+
+var (
+	defaultFuncsMu sync.RWMutex
+	defaultFuncs   = make(map[string]func() (string, error))
+)
+
+// RegisterDefaultFunc registers fn under name for defaults that cannot be
+// written down in advance, such as the host name. A field tagged
+// default:"$fn:name" calls fn whenever its default is needed. An error
+// returned by fn aborts processing.
+func RegisterDefaultFunc(name string, fn func() (string, error)) {
+	defaultFuncsMu.Lock()
+	defer defaultFuncsMu.Unlock()
+	defaultFuncs[name] = fn
 }
 
-// GatherInfo gathers information about the specified struct
-func gatherInfo(prefix string, spec interface{}) ([]varInfo, error) {
-	s := reflect.ValueOf(spec)
+// defaultValue returns the default def of info, expanded or computed by a
+// registered function.
+func defaultValue(info varInfo, def string, lookup lookupFunc) (string, error) {
+	if !strings.HasPrefix(def, defaultFuncPrefix) {
+		return info.expand(def, lookup, "default of "+info.Key)
+	}
+	name := def[len(defaultFuncPrefix):]
+	defaultFuncsMu.RLock()
+	fn, ok := defaultFuncs[name]
+	defaultFuncsMu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("envconfig: default of %s refers to unknown function %s", info.Key, name)
+	}
+	value, err := fn()
+	if err != nil {
+		return "", fmt.Errorf("envconfig: default of %s: %w", info.Key, err)
+	}
+	return value, nil
+}
 
-	if s.Kind() != reflect.Ptr {
-		return nil, ErrInvalidSpecification
+// lookupTemplate builds the value of info from the variables referenced by
+// tmpl. It reports false if none of them is set. Undefined variables expand to
+// the empty string unless the template is strict.
+func lookupTemplate(info varInfo, tmpl string, lookup lookupFunc) (resolution, bool, error) {
+	value, found, missing, err := expandRefs(tmpl, lookup)
+	if err != nil || !found {
+		return resolution{}, false, err
 	}
-	s = s.Elem()
-	if s.Kind() != reflect.Struct {
-		return nil, ErrInvalidSpecification
+	if len(missing) > 0 && isTrue(info.Tags.Get("template_strict")) {
+		return resolution{}, false, undefinedRefsError("template of "+info.Key, missing)
 	}
-	typeOfSpec := s.Type()
+	return resolution{value: value, key: info.Key, source: SourceEnv}, true, nil
+}
 
-	// over allocate an info array, we will extend if needed later
-	infos := make([]varInfo, 0, s.NumField())
-	for i := 0; i < s.NumField(); i++ {
-		f := s.Field(i)
-		ftype := typeOfSpec.Field(i)
-		if !f.CanSet() || isTrue(ftype.Tag.Get("ignored")) {
-			continue
-		}
+// assignInfo converts the resolved value and assigns it to the field of info.
+func (p *Processor) assignInfo(info varInfo, r resolution) error {
+	// errors name the key that supplied the value rather than the key of the
+	// field, which differs when the value came from the alt key
+	info.Key = r.key
 
-		for f.Kind() == reflect.Ptr {
-			if f.IsNil() {
-				if f.Type().Elem().Kind() != reflect.Struct {
-					// nil pointer to a non-struct: leave it alone
-					break
-				}
-				// nil pointer to struct: create a zero instance
-				f.Set(reflect.New(f.Type().Elem()))
-			}
+	// allocate the optional sections the field is in
+	if info.root.IsValid() {
+		f := fieldByIndex(info.root, info.index)
+		for f.Kind() == reflect.Ptr && !f.IsNil() {
 			f = f.Elem()
 		}
+		info.Field = f
+	}
 
-		// Capture information about the config variable
-		info := varInfo{
-			Name:  ftype.Name,
-			Field: f,
-			Tags:  ftype.Tag,
-			Alt:   strings.ToUpper(ftype.Tag.Get("envconfig")),
-		}
-
-		// Default to the field name as the env var name (will be upcased)
-		info.Key = info.Name
+	value := r.value
+	raw := value
+	if isTrue(info.Tags.Get("trim")) {
+		value = strings.TrimSpace(value)
+	}
+	value, err := decodeValue(value, info.Tags.Get("encoding"))
+	if err != nil {
+		return info.parseError(raw, err)
+	}
 
-		// Best effort to un-pick camel casing as separate words
-		if isTrue(ftype.Tag.Get("split_words")) {
-			words := gatherRegexp.FindAllStringSubmatch(ftype.Name, -1)
-			if len(words) > 0 {
-				var name []string
-				for _, words := range words {
-					if m := acronymRegexp.FindStringSubmatch(words[0]); len(m) == 3 {
-						name = append(name, m[1], m[2])
-					} else {
-						name = append(name, words[0])
-					}
-				}
+	if info.pattern != nil && !info.pattern.MatchString(value) {
+		return info.parseError(raw, fmt.Errorf("value does not match pattern %s", info.pattern))
+	}
 
-				info.Key = strings.Join(name, "_")
+	if allowed := strings.Fields(info.Tags.Get("oneof")); len(allowed) > 0 {
+		found := false
+		ignoreCase := isTrue(info.Tags.Get("oneof_ignore_case"))
+		for _, a := range allowed {
+			if a == value || ignoreCase && strings.EqualFold(a, value) {
+				// the spelling of the tag is assigned, whatever the case
+				value, found = a, true
+				break
 			}
 		}
-		if info.Alt != "" {
-			info.Key = info.Alt
-		}
-		if prefix != "" {
-			info.Key = fmt.Sprintf("%s_%s", prefix, info.Key)
+		if !found {
+			return info.parseError(raw, fmt.Errorf("value must be one of %s", strings.Join(allowed, ", ")))
 		}
-		info.Key = strings.ToUpper(info.Key)
-		infos = append(infos, info)
+	}
 
-		if f.Kind() == reflect.Struct {
-			// honor Decode if present
-			if decoderFrom(f) == nil && setterFrom(f) == nil && textUnmarshaler(f) == nil && binaryUnmarshaler(f) == nil {
-				innerPrefix := prefix
-				if !ftype.Anonymous {
-					innerPrefix = info.Key
-				}
+	// keep the previous value around so bounds violations leave it untouched
+	var prev reflect.Value
+	if info.Tags.Get("min") != "" || info.Tags.Get("max") != "" {
+		prev = reflect.New(info.Field.Type()).Elem()
+		prev.Set(info.Field)
+	}
 
-				embeddedPtr := f.Addr().Interface()
-				embeddedInfos, err := gatherInfo(innerPrefix, embeddedPtr)
-				if err != nil {
-					return nil, err
-				}
-				infos = append(infos[:len(infos)-1], embeddedInfos...)
+	err = p.processField(value, info.Field, info.Tags)
+	if err != nil {
+		return info.parseError(raw, err)
+	}
 
-				continue
-			}
+	if prev.IsValid() {
+		if err := p.checkBounds(info.Field, info.Tags); err != nil {
+			info.Field.Set(prev)
+			return info.parseError(raw, err)
 		}
 	}
-	return infos, nil
+
+	return nil
 }
 
-// CheckDisallowed checks that no environment variables with the prefix are set
-// that we don't know how or want to parse. This is likely only meaningful with
-// a non-empty prefix.
-func CheckDisallowed(prefix string, spec interface{}) error {
-	infos, err := gatherInfo(prefix, spec)
+// SetField converts value into field by the same rules Process uses, including
+// the type specific tags in tag such as separator, format, unit and encoding,
+// which decodes base64, hex, url and json values first. It makes it possible
+// to build other loaders on top of the conversions of this package. The field
+// must be settable.
+//
+// Lookups, defaults and checks such as pattern, min and max are not part of
+// the conversion and are not applied.
+func SetField(field reflect.Value, value string, tag reflect.StructTag) error {
+	return (&Processor{}).SetField(field, value, tag)
+}
+
+// SetField is the same as the SetField function but also uses the decoders
+// registered on p.
+func (p *Processor) SetField(field reflect.Value, value string, tag reflect.StructTag) error {
+	if !field.CanSet() {
+		return errors.New("envconfig: field is not settable")
+	}
+	value, err := decodeValue(value, tag.Get("encoding"))
 	if err != nil {
 		return err
 	}
+	return p.processField(value, field, tag)
+}
 
-	vars := make(map[string]struct{})
-	for _, info := range infos {
-		vars[info.Key] = struct{}{}
+// MustProcess is the same as Process but panics if an error occurs
+func MustProcess(prefix string, spec interface{}) {
+	if err := Process(prefix, spec); err != nil {
+		panic(err)
 	}
+}
 
-	if prefix != "" {
-		prefix = strings.ToUpper(prefix) + "_"
+// MustProcessWith is the same as ProcessWith but panics if an error occurs
+func MustProcessWith(prefix string, spec interface{}, lookup func(key string) (string, bool)) {
+	if err := ProcessWith(prefix, spec, lookup); err != nil {
+		panic(err)
 	}
+}
 
-	for _, env := range os.Environ() {
-		if !strings.HasPrefix(env, prefix) {
-			continue
-		}
-		v := strings.SplitN(env, "=", 2)[0]
-		if _, found := vars[v]; !found {
-			return fmt.Errorf("unknown environment variable %s", v)
-		}
+// MustProcessContext is the same as ProcessContext but panics if an error
+// occurs
+func MustProcessContext(ctx context.Context, prefix string, spec interface{}, lookup func(ctx context.Context, key string) (string, bool, error)) {
+	if err := ProcessContext(ctx, prefix, spec, lookup); err != nil {
+		panic(err)
 	}
-
-	return nil
 }
 
-// Process populates the specified struct based on environment variables
-func Process(prefix string, spec interface{}) error {
-	infos, err := gatherInfo(prefix, spec)
+func (p *Processor) processField(value string, field reflect.Value, tags reflect.StructTag) error {
+	// the tags apply to the elements of slices, arrays and maps as well, so
+	// that a human edited list such as "a, b, c" is trimmed item by item
+	if isTrue(tags.Get("trim")) {
+		value = strings.TrimSpace(value)
+	}
+	if ok, err := p.decode(value, field); ok {
+		return err
+	}
 
-	for _, info := range infos {
+	typ := field.Type()
 
-		// `os.Getenv` cannot differentiate between an explicitly set empty value
-		// and an unset value. `os.LookupEnv` is preferred to `syscall.Getenv`,
-		// but it is only available in go1.5 or newer. We're using Go build tags
-		// here to use os.LookupEnv for >=go1.5
-		value, ok := lookupEnv(info.Key)
-		if !ok && info.Alt != "" {
-			value, ok = lookupEnv(info.Alt)
+	// allocate nil pointers first so that the interfaces below are never
+	// invoked on a nil receiver
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+		if field.IsNil() {
+			field.Set(reflect.New(typ))
 		}
+		field = field.Elem()
 
-		def := info.Tags.Get("default")
-		if def != "" && !ok {
-			value = def
+		if ok, err := p.decode(value, field); ok {
+			return err
 		}
+	}
 
-		req := info.Tags.Get("required")
-		if !ok && def == "" {
-			if isTrue(req) {
-				key := info.Key
-				if info.Alt != "" {
-					key = info.Alt
-				}
-				return fmt.Errorf("required key %s missing value", key)
-			}
-			continue
-		}
+	// JSON values are unmarshaled as a whole, whatever the type of the field
+	if tags.Get("encoding") == "json" {
+		return json.Unmarshal([]byte(value), field.Addr().Interface())
+	}
 
-		err = processField(value, info.Field)
+	// net.IPNet has no unmarshal method of its own
+	if typ == ipNetType {
+		_, n, err := net.ParseCIDR(value)
 		if err != nil {
-			return &ParseError{
-				KeyName:   info.Key,
-				FieldName: info.Name,
-				TypeName:  info.Field.Type().String(),
-				Value:     value,
-				Err:       err,
-			}
+			return err
 		}
+		field.Set(reflect.ValueOf(*n))
+		return nil
 	}
 
-	return err
-}
-
-// MustProcess is the same as Process but panics if an error occurs
-func MustProcess(prefix string, spec interface{}) {
-	if err := Process(prefix, spec); err != nil {
-		panic(err)
+	// big numbers take any base SetString understands, prefixes included
+	switch typ {
+	case bigIntType:
+		if _, ok := field.Addr().Interface().(*big.Int).SetString(value, 0); !ok {
+			return fmt.Errorf("invalid big.Int %q", value)
+		}
+		return nil
+	case bigFloatType:
+		f := field.Addr().Interface().(*big.Float)
+		// SetString rounds to 64 bits unless a precision is set. Four bits
+		// per character hold any decimal or hexadecimal mantissa.
+		if f.Prec() == 0 {
+			prec := uint(len(value)) * 4
+			if prec < 64 {
+				prec = 64
+			}
+			f.SetPrec(prec)
+		}
+		if _, ok := f.SetString(value); !ok {
+			return fmt.Errorf("invalid big.Float %q", value)
+		}
+		return nil
 	}
-}
 
-func processField(value string, field reflect.Value) error {
-	typ := field.Type()
+	// time.Time is parsed as RFC 3339 by its UnmarshalText method unless a
+	// different layout is given in the format tag, which applies to the
+	// elements of slices and maps the same way
+	if layout := tags.Get("format"); layout != "" && typ == timeType {
+		t, err := time.Parse(layout, value)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(t))
+		return nil
+	}
 
 	decoder := decoderFrom(field)
 	if decoder != nil {
@@ -255,12 +2015,30 @@ func processField(value string, field reflect.Value) error {
 		return b.UnmarshalBinary([]byte(value))
 	}
 
-	if typ.Kind() == reflect.Ptr {
-		typ = typ.Elem()
-		if field.IsNil() {
-			field.Set(reflect.New(typ))
+	// runes and bytes tagged char hold a single character instead of a number
+	if isTrue(tags.Get("char")) && (typ.Kind() == reflect.Int32 || typ.Kind() == reflect.Uint8) {
+		return setChar(value, field)
+	}
+
+	// numbers and booleans never hold white space, such as a trailing
+	// newline copied along with the value
+	switch typ.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		value = strings.TrimSpace(value)
+	}
+
+	if isTrue(tags.Get("grouped")) && typ != durationType {
+		switch typ.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+			var err error
+			if value, err = ungroup(value); err != nil {
+				return err
+			}
 		}
-		field = field.Elem()
 	}
 
 	switch typ.Kind() {
@@ -271,79 +2049,362 @@ func processField(value string, field reflect.Value) error {
 			val int64
 			err error
 		)
-		if field.Kind() == reflect.Int64 && typ.PkgPath() == "time" && typ.Name() == "Duration" {
+		if typ == durationType {
 			var d time.Duration
-			d, err = time.ParseDuration(value)
+			d, err = parseDuration(value, tags.Get("unit"))
 			val = int64(d)
+		} else if tags.Get("unit") == "bytes" {
+			val, err = parseIntBytes(value, typ.Bits())
 		} else {
-			val, err = strconv.ParseInt(value, 0, typ.Bits())
+			var base int
+			if base, err = intBase(tags); err == nil {
+				val, err = strconv.ParseInt(value, base, typ.Bits())
+			}
 		}
 		if err != nil {
-			return err
+			return numError(err, typ)
 		}
 
 		field.SetInt(val)
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		val, err := strconv.ParseUint(value, 0, typ.Bits())
+		parse := func(value string) (uint64, error) {
+			if tags.Get("unit") == "bytes" {
+				return parseUintBytes(value, typ.Bits())
+			}
+			base, err := intBase(tags)
+			if err != nil {
+				return 0, err
+			}
+			// permission bits are octal, with or without a prefix
+			if typ == fileModeType && tags.Get("base") == "" {
+				base = 8
+				value = strings.TrimPrefix(strings.TrimPrefix(value, "0o"), "0O")
+			}
+			return strconv.ParseUint(value, base, typ.Bits())
+		}
+		// only a number after the minus sign makes the value negative, any
+		// other value is invalid syntax
+		if strings.HasPrefix(value, "-") {
+			if _, err := parse(value[1:]); err == nil || errors.Is(err, strconv.ErrRange) {
+				return &negativeError{typ: typ, value: value}
+			}
+		}
+		val, err := parse(value)
 		if err != nil {
-			return err
+			return numError(err, typ)
 		}
 		field.SetUint(val)
 	case reflect.Bool:
-		val, err := strconv.ParseBool(value)
+		val, err := parseBool(value, tags.Get("bool") == "lenient")
 		if err != nil {
 			return err
 		}
+		// negated variables such as NO_COLOR hold the opposite of the field
+		if isTrue(tags.Get("negate")) {
+			val = !val
+		}
 		field.SetBool(val)
 	case reflect.Float32, reflect.Float64:
 		val, err := strconv.ParseFloat(value, typ.Bits())
 		if err != nil {
-			return err
+			return numError(err, typ)
 		}
 		field.SetFloat(val)
+	case reflect.Complex64, reflect.Complex128:
+		val, err := parseComplex(value, typ.Bits())
+		if err != nil {
+			return numError(err, typ)
+		}
+		field.SetComplex(val)
 	case reflect.Slice:
 		sl := reflect.MakeSlice(typ, 0, 0)
 		if typ.Elem().Kind() == reflect.Uint8 {
 			sl = reflect.ValueOf([]byte(value))
 		} else if len(strings.TrimSpace(value)) != 0 {
-			vals := strings.Split(value, ",")
+			vals := strings.Split(value, separator(tags))
 			sl = reflect.MakeSlice(typ, len(vals), len(vals))
 			for i, val := range vals {
-				err := processField(val, sl.Index(i))
+				err := p.processField(val, sl.Index(i), tags)
 				if err != nil {
-					return err
+					return fmt.Errorf("element %d: %w", i, err)
 				}
 			}
 		}
 		field.Set(sl)
+	case reflect.Array:
+		var vals []string
+		if len(strings.TrimSpace(value)) != 0 {
+			vals = strings.Split(value, separator(tags))
+		}
+		if len(vals) != typ.Len() {
+			return fmt.Errorf("expected %d elements, got %d", typ.Len(), len(vals))
+		}
+		arr := reflect.New(typ).Elem()
+		for i, val := range vals {
+			err := p.processField(val, arr.Index(i), tags)
+			if err != nil {
+				return fmt.Errorf("element %d: %w", i, err)
+			}
+		}
+		field.Set(arr)
 	case reflect.Map:
 		mp := reflect.MakeMap(typ)
 		if len(strings.TrimSpace(value)) != 0 {
-			pairs := strings.Split(value, ",")
+			pairs := strings.Split(value, separator(tags))
 			for _, pair := range pairs {
-				kvpair := strings.Split(pair, ":")
+				// maps of empty structs are sets, every item is a key
+				if typ.Elem().Kind() == reflect.Struct && typ.Elem().NumField() == 0 {
+					k := reflect.New(typ.Key()).Elem()
+					if err := p.processField(pair, k, tags); err != nil {
+						return fmt.Errorf("key %q: %w", pair, err)
+					}
+					mp.SetMapIndex(k, reflect.New(typ.Elem()).Elem())
+					continue
+				}
+				// values may hold the separator, as URLs hold colons
+				kvpair := strings.SplitN(pair, kvSeparator(tags), 2)
 				if len(kvpair) != 2 {
 					return fmt.Errorf("invalid map item: %q", pair)
 				}
 				k := reflect.New(typ.Key()).Elem()
-				err := processField(kvpair[0], k)
+				err := p.processField(kvpair[0], k, tags)
 				if err != nil {
-					return err
+					return fmt.Errorf("key %q: %w", kvpair[0], err)
 				}
 				v := reflect.New(typ.Elem()).Elem()
-				err = processField(kvpair[1], v)
+				err = p.processField(kvpair[1], v, tags)
 				if err != nil {
-					return err
+					return fmt.Errorf("key %q: %w", kvpair[0], err)
 				}
 				mp.SetMapIndex(k, v)
 			}
 		}
 		field.Set(mp)
+	case reflect.Interface:
+		// only an empty interface can hold whatever the value turns out to be
+		if typ.NumMethod() > 0 {
+			return ErrUnsupportedFieldType
+		}
+		if isTrue(tags.Get("infer")) {
+			field.Set(reflect.ValueOf(inferValue(value)))
+		} else {
+			field.Set(reflect.ValueOf(value))
+		}
+	default:
+		return ErrUnsupportedFieldType
+	}
+
+	return nil
+}
+
+// inferValue returns value as the first type that can read it, in this
+// order: an int, a float64 written as a finite decimal number, a bool as
+// strconv.ParseBool understands it and otherwise the string itself. Words
+// such as inf or NaN and hexadecimal floats stay strings.
+func inferValue(value string) interface{} {
+	if i, err := strconv.Atoi(value); err == nil {
+		return i
+	}
+	// only digits, signs, points and exponents are left of a decimal number
+	if f, err := strconv.ParseFloat(value, 64); err == nil && strings.Trim(value, "0123456789+-.eE") == "" {
+		return f
+	}
+	if b, err := strconv.ParseBool(value); err == nil {
+		return b
+	}
+	return value
+}
+
+// expand replaces ${var} or $var references in s with their values from
+// lookup. Undefined variables expand to the empty string, like in a shell,
+// unless info is tagged expand_strict. Then they are an error naming what
+// refers to them. The names are part of the value, so they are left out for
+// secret fields.
+func (info varInfo) expand(s string, lookup lookupFunc, what string) (string, error) {
+	if !strings.Contains(s, "$") {
+		return s, nil
+	}
+	s, _, missing, err := expandRefs(s, lookup)
+	if err == nil && isTrue(info.Tags.Get("expand_strict")) && len(missing) > 0 {
+		if info.secret() {
+			missing = nil
+		}
+		err = undefinedRefsError(what, missing)
+	}
+	return s, err
+}
+
+// expandRefs replaces the references in s like expand. It reports whether any
+// of them is defined and returns the names of those that are not.
+func expandRefs(s string, lookup lookupFunc) (value string, found bool, missing []string, err error) {
+	value = os.Expand(s, func(key string) string {
+		value, ok, lerr := lookup(key)
+		if lerr != nil && err == nil {
+			err = lerr
+		}
+		if ok {
+			found = true
+		} else {
+			missing = append(missing, key)
+		}
+		return value
+	})
+	return value, found, missing, err
+}
+
+// undefinedRefsError reports the undefined references of a strict template,
+// default or value, or that there are some if missing is nil.
+func undefinedRefsError(what string, missing []string) error {
+	if missing == nil {
+		return fmt.Errorf("envconfig: %s refers to undefined variables", what)
+	}
+	return fmt.Errorf("envconfig: %s refers to undefined %s", what, strings.Join(missing, ", "))
+}
+
+// setChar assigns the single character in value to a rune or byte field. A
+// byte only holds characters that are encoded in a single byte.
+func setChar(value string, field reflect.Value) error {
+	if field.Kind() == reflect.Uint8 {
+		if len(value) != 1 {
+			return fmt.Errorf("expected a single byte character, got %d bytes", len(value))
+		}
+		field.SetUint(uint64(value[0]))
+		return nil
+	}
+	if n := utf8.RuneCountInString(value); n != 1 {
+		return fmt.Errorf("expected a single character, got %d", n)
+	}
+	r, _ := utf8.DecodeRuneInString(value)
+	field.SetInt(int64(r))
+	return nil
+}
+
+// lenientBools are the words accepted for booleans tagged bool:"lenient" on
+// top of the ones strconv.ParseBool understands.
+var lenientBools = map[string]bool{
+	"yes":      true,
+	"no":       false,
+	"on":       true,
+	"off":      false,
+	"enabled":  true,
+	"disabled": false,
+}
+
+// parseBool parses a boolean with strconv.ParseBool. Lenient parsing also
+// accepts yes/no, on/off and enabled/disabled in any case.
+func parseBool(value string, lenient bool) (bool, error) {
+	if lenient {
+		if b, ok := lenientBools[strings.ToLower(value)]; ok {
+			return b, nil
+		}
+	}
+	return strconv.ParseBool(value)
+}
+
+// decodeValue undoes the transport encoding named by the encoding tag before a
+// value is converted. Values are used as is when no encoding is given. JSON is
+// not a transport encoding and is unmarshaled by processField instead.
+func decodeValue(value, encoding string) (string, error) {
+	switch encoding {
+	case "", "raw", "json":
+		return value, nil
+	case "base64":
+		b, err := base64.StdEncoding.DecodeString(value)
+		return string(b), err
+	case "hex":
+		b, err := hex.DecodeString(value)
+		return string(b), err
+	case "url":
+		return url.QueryUnescape(value)
+	}
+	return "", fmt.Errorf("unknown encoding %q", encoding)
+}
+
+// checkBounds verifies that a numeric field lies within the limits given by
+// its min and max tags. The limits are parsed like a value of the field so
+// they may use the same notation, e.g. a duration or a byte size.
+func (p *Processor) checkBounds(field reflect.Value, tags reflect.StructTag) error {
+	for field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			return nil
+		}
+		field = field.Elem()
 	}
 
+	for _, bound := range []string{"min", "max"} {
+		limit := tags.Get(bound)
+		if limit == "" {
+			continue
+		}
+		l := reflect.New(field.Type()).Elem()
+		if err := p.processField(limit, l, tags); err != nil {
+			return fmt.Errorf("invalid %s tag %q: %v", bound, limit, err)
+		}
+
+		var cmp int
+		switch field.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			cmp = compare(field.Int() < l.Int(), field.Int() > l.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			cmp = compare(field.Uint() < l.Uint(), field.Uint() > l.Uint())
+		case reflect.Float32, reflect.Float64:
+			cmp = compare(field.Float() < l.Float(), field.Float() > l.Float())
+		default:
+			return fmt.Errorf("%s tag is not supported for type %s", bound, field.Type())
+		}
+
+		if bound == "min" && cmp < 0 {
+			return fmt.Errorf("less than minimum %s", limit)
+		}
+		if bound == "max" && cmp > 0 {
+			return fmt.Errorf("greater than maximum %s", limit)
+		}
+	}
 	return nil
 }
 
+func compare(less, greater bool) int {
+	switch {
+	case less:
+		return -1
+	case greater:
+		return 1
+	}
+	return 0
+}
+
+// intBase returns the base integers are parsed in. The default of 0 detects
+// the base from a 0x, 0o, 0b or 0 prefix.
+func intBase(tags reflect.StructTag) (int, error) {
+	b := tags.Get("base")
+	if b == "" {
+		return 0, nil
+	}
+	base, err := strconv.Atoi(b)
+	if err != nil || base != 0 && (base < 2 || base > 36) {
+		return 0, fmt.Errorf("%q is not 0 or between 2 and 36", b)
+	}
+	return base, nil
+}
+
+// separator returns the string used to split slice elements and map pairs,
+// taken from the separator tag and defaulting to a comma.
+func separator(tags reflect.StructTag) string {
+	if sep := tags.Get("separator"); sep != "" {
+		return sep
+	}
+	return ","
+}
+
+// kvSeparator returns the string used to split a map pair into its key and
+// value, taken from the kv_separator tag and defaulting to a colon.
+func kvSeparator(tags reflect.StructTag) string {
+	if sep := tags.Get("kv_separator"); sep != "" {
+		return sep
+	}
+	return ":"
+}
+
 func interfaceFrom(field reflect.Value, fn func(interface{}, *bool)) {
 	// it may be impossible for a struct field to fail this check
 	if !field.CanInterface() {
@@ -0,0 +1,54 @@
+// +build go1.15
+
+package envconfig
+
+import (
+	"os"
+	"testing"
+)
+
+type SpecWithComplex struct {
+	Impedance complex64
+	Signal    complex128
+	Samples   []complex128
+}
+
+func TestParseComplex(t *testing.T) {
+	var s SpecWithComplex
+
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_IMPEDANCE", "50+2.5i")
+	os.Setenv("ENV_CONFIG_SIGNAL", "(1-1i)")
+	os.Setenv("ENV_CONFIG_SAMPLES", "1,2i,-3+4i")
+
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if s.Impedance != 50+2.5i {
+		t.Errorf("expected %v, got %v", 50+2.5i, s.Impedance)
+	}
+	if s.Signal != 1-1i {
+		t.Errorf("expected %v, got %v", 1-1i, s.Signal)
+	}
+	if len(s.Samples) != 3 || s.Samples[1] != 2i || s.Samples[2] != -3+4i {
+		t.Errorf("expected %v, got %v", []complex128{1, 2i, -3 + 4i}, s.Samples)
+	}
+}
+
+func TestParseComplexError(t *testing.T) {
+	var s SpecWithComplex
+
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_SIGNAL", "1+i2")
+
+	err := Process("env_config", &s)
+
+	v, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("expected ParseError, got %T %v", err, err)
+	}
+	if v.FieldName != "Signal" {
+		t.Errorf("expected %s, got %v", "Signal", v.FieldName)
+	}
+}
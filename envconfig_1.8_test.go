@@ -66,3 +66,27 @@ func TestParseURLError(t *testing.T) {
 		t.Errorf("expected %q, got %q", expectedUnerlyingError, v.Err)
 	}
 }
+
+func TestParseURLValueError(t *testing.T) {
+	var s SpecWithURL
+
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_URLVALUE", "http://[::1")
+
+	err := Process("env_config", &s)
+
+	v, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("expected ParseError, got %T %v", err, err)
+	}
+	if v.FieldName != "UrlValue" {
+		t.Errorf("expected %s, got %v", "UrlValue", v.FieldName)
+	}
+	var urlErr *url.Error
+	if !errors.As(v, &urlErr) {
+		t.Errorf("expected %v to wrap a url.Error", v)
+	}
+	if s.UrlPointer != nil {
+		t.Errorf("expected <nil>, got %v", s.UrlPointer)
+	}
+}
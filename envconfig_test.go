@@ -5,10 +5,17 @@
 package envconfig
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
 	"net/url"
 	"os"
+	"reflect"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -198,7 +205,7 @@ func TestProcess(t *testing.T) {
 	}
 
 	if s.MultiWordVarWithAutoSplit != 24 {
-		t.Errorf("expected %q, got %q", 24, s.MultiWordVarWithAutoSplit)
+		t.Errorf("expected %d, got %d", 24, s.MultiWordVarWithAutoSplit)
 	}
 
 	if s.MultiWordACRWithAutoSplit != 25 {
@@ -290,6 +297,199 @@ func TestParseErrorUint(t *testing.T) {
 	}
 }
 
+func TestParseErrorNegativeUint(t *testing.T) {
+	var s struct {
+		Workers uint
+		Size    uint32 `unit:"bytes"`
+		Token   uint   `secret:"true"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_WORKERS", "-1")
+	os.Setenv("ENV_CONFIG_SIZE", "-1MB")
+	os.Setenv("ENV_CONFIG_TOKEN", "-12345")
+	err := ProcessAll("env_config", &s)
+	errs, ok := err.(Errors)
+	if !ok || len(errs) != 3 {
+		t.Fatalf("expected 3 errors, got %v", err)
+	}
+	if want := `negative value "-1" not allowed for unsigned field of type uint`; errs[0].(*ParseError).Err.Error() != want {
+		t.Errorf("expected %q, got %q", want, errs[0].(*ParseError).Err)
+	}
+	if !errors.Is(errs[1], strconv.ErrRange) {
+		t.Errorf("expected %v to wrap strconv.ErrRange", errs[1])
+	}
+	if strings.Contains(errs[2].Error(), "12345") {
+		t.Errorf("expected the secret value to be masked, got %q", errs[2])
+	}
+}
+
+func TestParseErrorNegativeSyntax(t *testing.T) {
+	var s struct {
+		Workers uint
+		Size    uint32 `unit:"bytes"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_WORKERS", "-abc")
+	os.Setenv("ENV_CONFIG_SIZE", "-")
+	errs, ok := ProcessAll("env_config", &s).(Errors)
+	if !ok || len(errs) != 2 {
+		t.Fatalf("expected 2 errors, got %v", errs)
+	}
+	for _, err := range errs {
+		if errors.Is(err, strconv.ErrRange) || strings.Contains(err.Error(), "negative") {
+			t.Errorf("expected a syntax error, got %v", err)
+		}
+	}
+	if !errors.Is(errs[0], strconv.ErrSyntax) {
+		t.Errorf("expected %v to wrap strconv.ErrSyntax", errs[0])
+	}
+}
+
+func TestParseErrorOutOfRange(t *testing.T) {
+	var s struct {
+		Small int8
+		Count uint16
+		Limit int8
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_SMALL", "300")
+	os.Setenv("ENV_CONFIG_COUNT", "70000")
+	os.Setenv("ENV_CONFIG_LIMIT", "ten")
+	err := ProcessAll("env_config", &s)
+	errs, ok := err.(Errors)
+	if !ok || len(errs) != 3 {
+		t.Fatalf("expected 3 errors, got %v", err)
+	}
+
+	for i, want := range []string{"value out of range for int8", "value out of range for uint16"} {
+		v, ok := errs[i].(*ParseError)
+		if !ok {
+			t.Fatalf("expected ParseError, got %v", errs[i])
+		}
+		if v.Err.Error() != want {
+			t.Errorf("expected %q, got %q", want, v.Err)
+		}
+		if !errors.Is(v, strconv.ErrRange) {
+			t.Errorf("expected %v to wrap strconv.ErrRange", v)
+		}
+	}
+	if v := errs[0].(*ParseError); v.Value != "300" || v.FieldName != "Small" {
+		t.Errorf("expected value and field to be kept, got %#v", v)
+	}
+	if errors.Is(errs[2], strconv.ErrRange) || !errors.Is(errs[2], strconv.ErrSyntax) {
+		t.Errorf("expected a syntax error, got %v", errs[2])
+	}
+}
+
+func TestErrorsIsAs(t *testing.T) {
+	var s struct {
+		Port  int
+		Debug bool
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_PORT", "99999999999999999999")
+	os.Setenv("ENV_CONFIG_DEBUG", "maybe")
+	errs, ok := ProcessAll("env_config", &s).(Errors)
+	if !ok || len(errs) != 2 {
+		t.Fatalf("expected 2 errors, got %v", errs)
+	}
+	if !errs.Is(strconv.ErrRange) || !errs.Is(strconv.ErrSyntax) || errs.Is(ErrRequired) {
+		t.Errorf("expected Is to match the errors of the elements, got %v", errs)
+	}
+	var pe *ParseError
+	if !errs.As(&pe) || pe.FieldName != "Port" {
+		t.Errorf("expected As to find the first ParseError, got %v", pe)
+	}
+}
+
+func TestParseErrorUnwrap(t *testing.T) {
+	var s struct {
+		Debug   bool
+		Rate    float64
+		Port    int
+		Timeout time.Duration
+		Started time.Time `format:"2006-01-02"`
+		Token   int       `secret:"true"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_DEBUG", "maybe")
+	os.Setenv("ENV_CONFIG_RATE", "fast")
+	os.Setenv("ENV_CONFIG_PORT", "http")
+	os.Setenv("ENV_CONFIG_TIMEOUT", "soon")
+	os.Setenv("ENV_CONFIG_STARTED", "yesterday")
+	os.Setenv("ENV_CONFIG_TOKEN", "hunter2")
+	errs, ok := ProcessAll("env_config", &s).(Errors)
+	if !ok || len(errs) != 6 {
+		t.Fatalf("expected 6 errors, got %v", errs)
+	}
+
+	for _, i := range []int{0, 1, 2} {
+		var numErr *strconv.NumError
+		if !errors.As(errs[i], &numErr) || numErr.Err != strconv.ErrSyntax {
+			t.Errorf("expected %v to wrap strconv.ErrSyntax", errs[i])
+		}
+	}
+	// secret fields keep the cause only, the NumError would quote the value
+	var numErr *strconv.NumError
+	if !errors.Is(errs[5], strconv.ErrSyntax) || errors.As(errs[5], &numErr) {
+		t.Errorf("expected %v to wrap strconv.ErrSyntax alone", errs[5])
+	}
+	if v := errs[3].(*ParseError); v.Err == nil {
+		t.Errorf("expected the duration error to be kept")
+	}
+	var timeErr *time.ParseError
+	if !errors.As(errs[4], &timeErr) {
+		t.Errorf("expected %v to wrap a time.ParseError", errs[4])
+	}
+}
+
+func TestParseErrorAltKeyName(t *testing.T) {
+	var s struct {
+		Port int `envconfig:"SERVICE_PORT"`
+	}
+	os.Clearenv()
+	os.Setenv("SERVICE_PORT", "http")
+	err := Process("env_config", &s)
+	v, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("expected ParseError, got %v", err)
+	}
+	if v.KeyName != "SERVICE_PORT" {
+		t.Errorf("expected %s, got %s", "SERVICE_PORT", v.KeyName)
+	}
+
+	os.Setenv("ENV_CONFIG_SERVICE_PORT", "https")
+	err = Process("env_config", &s)
+	v, ok = err.(*ParseError)
+	if !ok {
+		t.Fatalf("expected ParseError, got %v", err)
+	}
+	if v.KeyName != "ENV_CONFIG_SERVICE_PORT" || v.Value != "https" {
+		t.Errorf("expected %s with %q, got %s with %q", "ENV_CONFIG_SERVICE_PORT", "https", v.KeyName, v.Value)
+	}
+}
+
+func TestParseErrorSliceElement(t *testing.T) {
+	var s Specification
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_REQUIREDVAR", "foo")
+	os.Setenv("ENV_CONFIG_MAGICNUMBERS", "5,ten,20")
+	err := Process("env_config", &s)
+	v, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("expected ParseError, got %v", err)
+	}
+	if v.FieldName != "MagicNumbers" {
+		t.Errorf("expected %s, got %v", "MagicNumbers", v.FieldName)
+	}
+	if !strings.HasPrefix(v.Err.Error(), "element 1: ") {
+		t.Errorf("expected error to name element 1, got %q", v.Err)
+	}
+	if s.MagicNumbers != nil {
+		t.Errorf("expected <nil>, got %#v", s.MagicNumbers)
+	}
+}
+
 func TestParseErrorSplitWords(t *testing.T) {
 	var s Specification
 	os.Clearenv()
@@ -302,61 +502,2005 @@ func TestParseErrorSplitWords(t *testing.T) {
 	if v.FieldName != "MultiWordVarWithAutoSplit" {
 		t.Errorf("expected %s, got %v", "", v.FieldName)
 	}
-	if s.MultiWordVarWithAutoSplit != 0 {
-		t.Errorf("expected %v, got %v", 0, s.MultiWordVarWithAutoSplit)
+	if s.MultiWordVarWithAutoSplit != 0 {
+		t.Errorf("expected %v, got %v", 0, s.MultiWordVarWithAutoSplit)
+	}
+}
+
+func TestProcessAll(t *testing.T) {
+	var s Specification
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_DEBUG", "string")
+	os.Setenv("ENV_CONFIG_PORT", "string")
+	os.Setenv("ENV_CONFIG_USER", "Kelsey")
+	err := ProcessAll("env_config", &s)
+	errs, ok := err.(Errors)
+	if !ok {
+		t.Fatalf("expected Errors, got %T %v", err, err)
+	}
+	if len(errs) != 3 {
+		t.Fatalf("expected 3 errors, got %d: %v", len(errs), errs)
+	}
+	if v, ok := errs[0].(*ParseError); !ok || v.FieldName != "Debug" {
+		t.Errorf("expected ParseError for Debug, got %v", errs[0])
+	}
+	if v, ok := errs[1].(*ParseError); !ok || v.FieldName != "Port" {
+		t.Errorf("expected ParseError for Port, got %v", errs[1])
+	}
+	if !strings.Contains(errs[2].Error(), "ENV_CONFIG_REQUIREDVAR") {
+		t.Errorf("expected missing ENV_CONFIG_REQUIREDVAR, got %v", errs[2])
+	}
+	if lines := strings.Split(err.Error(), "\n"); len(lines) != 3 {
+		t.Errorf("expected one line per error, got %q", err.Error())
+	}
+	if s.User != "Kelsey" {
+		t.Errorf("expected %s, got %s", "Kelsey", s.User)
+	}
+
+	os.Setenv("ENV_CONFIG_DEBUG", "true")
+	os.Setenv("ENV_CONFIG_PORT", "8080")
+	os.Setenv("ENV_CONFIG_REQUIREDVAR", "foo")
+	if err := ProcessAll("env_config", &s); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
+
+func TestProcessGroup(t *testing.T) {
+	var (
+		db struct {
+			Host string `required:"true"`
+			Port int
+		}
+		cache struct {
+			TTL time.Duration
+		}
+	)
+	os.Clearenv()
+	os.Setenv("DB_PORT", "x")
+	os.Setenv("CACHE_TTL", "forever")
+	err := ProcessGroup(SpecWithPrefix{"db", &db}, SpecWithPrefix{"cache", &cache})
+	errs, ok := err.(Errors)
+	if !ok || len(errs) != 3 {
+		t.Fatalf("expected 3 errors, got %v", err)
+	}
+	if !errors.Is(errs[0], ErrRequired) {
+		t.Errorf("expected missing DB_HOST first, got %v", errs[0])
+	}
+	if v, ok := errs[2].(*ParseError); !ok || v.KeyName != "CACHE_TTL" {
+		t.Errorf("expected ParseError for CACHE_TTL, got %v", errs[2])
+	}
+
+	os.Setenv("DB_HOST", "localhost")
+	os.Setenv("DB_PORT", "5432")
+	os.Setenv("CACHE_TTL", "1m")
+	if err := ProcessGroup(SpecWithPrefix{"db", &db}, SpecWithPrefix{"cache", &cache}); err != nil {
+		t.Fatal(err.Error())
+	}
+	if db.Host != "localhost" || db.Port != 5432 || cache.TTL != time.Minute {
+		t.Errorf("expected both specs to be filled, got %+v and %+v", db, cache)
+	}
+
+	if err := ProcessGroup(SpecWithPrefix{"db", db}); err == nil || !errors.Is(err, ErrInvalidSpecification) {
+		t.Errorf("expected ErrInvalidSpecification, got %v", err)
+	}
+}
+
+func TestProcessWithReport(t *testing.T) {
+	var s struct {
+		Timeout int `envconfig:"REQUEST_TIMEOUT" aliases:"TIMEOUT"`
+		Port    int
+		Host    string `required:"true"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_TIMEOUT", "5")
+	os.Setenv("ENV_CONFIG_PORT", "string")
+	os.Setenv("ENV_CONFIG_HOTS", "typo")
+	report := ProcessWithReport("env_config", &s)
+	if len(report.Errors) != 2 {
+		t.Fatalf("expected 2 errors, got %v", report.Errors)
+	}
+	if v, ok := report.Errors[0].(*ParseError); !ok || v.FieldName != "Port" {
+		t.Errorf("expected ParseError for Port, got %v", report.Errors[0])
+	}
+	if !errors.Is(report.Errors[1], ErrRequired) {
+		t.Errorf("expected missing ENV_CONFIG_HOST, got %v", report.Errors[1])
+	}
+	want := []string{
+		"ENV_CONFIG_TIMEOUT is deprecated, use ENV_CONFIG_REQUEST_TIMEOUT instead",
+		"unknown environment variable ENV_CONFIG_HOTS",
+	}
+	if strings.Join(report.Warnings, "\n") != strings.Join(want, "\n") {
+		t.Errorf("expected %q, got %q", want, report.Warnings)
+	}
+	if s.Timeout != 5 {
+		t.Errorf("expected %d, got %d", 5, s.Timeout)
+	}
+
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_REQUEST_TIMEOUT", "5")
+	os.Setenv("ENV_CONFIG_HOST", "localhost")
+	report = ProcessWithReport("env_config", &s)
+	if len(report.Errors) != 0 || len(report.Warnings) != 0 {
+		t.Errorf("expected a clean report, got %+v", report)
+	}
+}
+
+func TestProcessWith(t *testing.T) {
+	var s Specification
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_PORT", "1")
+	env := map[string]string{
+		"ENV_CONFIG_PORT":        "8080",
+		"ENV_CONFIG_REQUIREDVAR": "foo",
+		"SERVICE_HOST":           "127.0.0.1",
+	}
+	lookup := func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+	if err := ProcessWith("env_config", &s, lookup); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if s.Port != 8080 {
+		t.Errorf("expected %d, got %d", 8080, s.Port)
+	}
+	if s.RequiredVar != "foo" {
+		t.Errorf("expected %s, got %s", "foo", s.RequiredVar)
+	}
+	if s.NoPrefixWithAlt != "127.0.0.1" {
+		t.Errorf("expected %s, got %s", "127.0.0.1", s.NoPrefixWithAlt)
+	}
+	if s.DefaultVar != "foobar" {
+		t.Errorf("expected %s, got %s", "foobar", s.DefaultVar)
+	}
+
+	os.Setenv("ENV_CONFIG_REQUIREDVAR", "bar")
+	if err := ProcessWith("env_config", &s, nil); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Port != 1 || s.RequiredVar != "bar" {
+		t.Errorf("expected a nil lookup to read the environment, got %d and %s", s.Port, s.RequiredVar)
+	}
+	var c Specification
+	if err := ProcessContext(context.Background(), "env_config", &c, nil); err != nil {
+		t.Fatal(err.Error())
+	}
+	if c.Port != 1 || c.RequiredVar != "bar" {
+		t.Errorf("expected a nil lookup to read the environment, got %d and %s", c.Port, c.RequiredVar)
+	}
+}
+
+func TestProcessPrefixes(t *testing.T) {
+	var s struct {
+		Host    string `required:"true"`
+		Port    int    `default:"80"`
+		Debug   bool
+		Region  string `envconfig:"REGION"`
+		Servers []struct {
+			Name string
+		}
+	}
+	os.Clearenv()
+	os.Setenv("PROD_HOST", "prod.example.com")
+	os.Setenv("DEFAULT_HOST", "localhost")
+	os.Setenv("DEFAULT_DEBUG", "true")
+	os.Setenv("REGION", "eu")
+	os.Setenv("DEFAULT_SERVERS_0_NAME", "a")
+	if err := ProcessPrefixes([]string{"prod", "default"}, &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Host != "prod.example.com" {
+		t.Errorf("expected the first prefix to win, got %s", s.Host)
+	}
+	if !s.Debug || s.Port != 80 || s.Region != "eu" {
+		t.Errorf("expected true, 80 and eu, got %v, %d and %s", s.Debug, s.Port, s.Region)
+	}
+	if len(s.Servers) != 1 || s.Servers[0].Name != "a" {
+		t.Errorf("expected one server from the base, got %+v", s.Servers)
+	}
+
+	os.Setenv("DEFAULT_REGION", "us")
+	if err := ProcessPrefixes([]string{"prod", "default"}, &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Region != "us" {
+		t.Errorf("expected prefixed keys to win over the unprefixed one, got %s", s.Region)
+	}
+
+	os.Clearenv()
+	err := ProcessPrefixes([]string{"prod", "default"}, &s)
+	var re *RequiredError
+	if !errors.As(err, &re) || re.KeyName != "PROD_HOST" {
+		t.Errorf("expected RequiredError for PROD_HOST, got %v", err)
+	}
+}
+
+func TestProcessPrefixesDeclaredPrefix(t *testing.T) {
+	var s struct {
+		_    struct{} `envconfig:"prefix=APP"`
+		Host string
+		Port int
+	}
+	os.Clearenv()
+	os.Setenv("APP_HOST", "example.com")
+	os.Setenv("PROD_HOST", "prod.example.com")
+	os.Setenv("DEFAULT_PORT", "1")
+	if err := ProcessPrefixes([]string{"prod", "default"}, &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Host != "example.com" || s.Port != 1 {
+		t.Errorf("expected the declared prefix to replace the first one, got %s and %d", s.Host, s.Port)
+	}
+}
+
+func TestProcessWithDefaults(t *testing.T) {
+	type limits struct {
+		Burst int
+	}
+	type config struct {
+		Port     int
+		Hosts    []string
+		Weights  map[string]int
+		Timeout  time.Duration
+		Limits   *limits
+		LogLevel string `default:"info"`
+	}
+	defaults := config{
+		Port:    8080,
+		Hosts:   []string{"a", "b"},
+		Weights: map[string]int{"a": 1},
+		Timeout: time.Minute,
+		Limits:  &limits{Burst: 10},
+	}
+
+	var s config
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_PORT", "9090")
+	os.Setenv("ENV_CONFIG_LIMITS_BURST", "20")
+	if err := ProcessWithDefaults("env_config", &s, &defaults); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Port != 9090 || s.Timeout != time.Minute || len(s.Hosts) != 2 || s.Weights["a"] != 1 || s.LogLevel != "info" {
+		t.Errorf("expected env values over the defaults, got %+v", s)
+	}
+	if s.Limits == nil || s.Limits.Burst != 20 {
+		t.Errorf("expected a burst of 20, got %+v", s.Limits)
+	}
+	if defaults.Limits.Burst != 10 || defaults.Port != 8080 {
+		t.Errorf("expected the defaults to be left alone, got %+v and %+v", defaults, defaults.Limits)
+	}
+
+	var other Specification
+	if err := ProcessWithDefaults("env_config", &other, &defaults); err == nil {
+		t.Error("expected an error for defaults of another type")
+	}
+	if err := ProcessWithDefaults("env_config", &s, defaults); err != ErrInvalidSpecification {
+		t.Errorf("expected %v, got %v", ErrInvalidSpecification, err)
+	}
+}
+
+func TestReload(t *testing.T) {
+	type database struct {
+		Host string
+		Port int `default:"5432"`
+	}
+	var s struct {
+		LogLevel string `default:"info"`
+		Hosts    []string
+		Weights  map[string]int
+		Timeout  time.Duration
+		DB       database
+		Cache    *database
+		Computed string `ignored:"true"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_HOSTS", "a,b")
+	os.Setenv("ENV_CONFIG_WEIGHTS", "a:1")
+	os.Setenv("ENV_CONFIG_DB_HOST", "db1")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	s.Computed = "kept"
+
+	changed, err := Reload("env_config", &s)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(changed) != 0 {
+		t.Errorf("expected nothing to change, got %v", changed)
+	}
+
+	os.Setenv("ENV_CONFIG_LOGLEVEL", "debug")
+	os.Setenv("ENV_CONFIG_WEIGHTS", "a:2")
+	os.Setenv("ENV_CONFIG_DB_HOST", "db2")
+	os.Setenv("ENV_CONFIG_CACHE_HOST", "cache")
+	changed, err = Reload("env_config", &s)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if want := "LogLevel,Weights,DB.Host,Cache"; strings.Join(changed, ",") != want {
+		t.Errorf("expected %s, got %v", want, changed)
+	}
+	if s.LogLevel != "debug" || s.DB.Host != "db2" || s.Cache == nil || s.Computed != "kept" {
+		t.Errorf("expected the changes to be applied, got %+v", s)
+	}
+
+	os.Setenv("ENV_CONFIG_CACHE_PORT", "6379")
+	os.Setenv("ENV_CONFIG_TIMEOUT", "1m")
+	changed, err = Reload("env_config", &s)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if want := "Timeout,Cache.Port"; strings.Join(changed, ",") != want {
+		t.Errorf("expected %s, got %v", want, changed)
+	}
+
+	os.Setenv("ENV_CONFIG_LOGLEVEL", "warn")
+	os.Setenv("ENV_CONFIG_DB_PORT", "invalid")
+	if _, err := Reload("env_config", &s); err == nil {
+		t.Error("expected an error")
+	}
+	if s.LogLevel != "debug" {
+		t.Errorf("expected spec to be left untouched on error, got %s", s.LogLevel)
+	}
+}
+
+func TestSetDefaultLookup(t *testing.T) {
+	var s Specification
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_PORT", "1")
+	os.Setenv("ENV_CONFIG_REQUIREDVAR", "foo")
+	env := map[string]string{
+		"ENV_CONFIG_PORT":        "8080",
+		"ENV_CONFIG_REQUIREDVAR": "bar",
+	}
+	restore := SetDefaultLookup(func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	})
+	if err := Process("env_config", &s); err != nil {
+		restore()
+		t.Fatal(err.Error())
+	}
+	if s.Port != 8080 {
+		t.Errorf("expected %d, got %d", 8080, s.Port)
+	}
+	if values, _ := Resolve("env_config", &s); values["ENV_CONFIG_REQUIREDVAR"] != "bar" {
+		t.Errorf("expected Resolve to use the default lookup, got %v", values["ENV_CONFIG_REQUIREDVAR"])
+	}
+
+	restore()
+	s = Specification{}
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Port != 1 {
+		t.Errorf("expected the environment to be restored, got %d", s.Port)
+	}
+}
+
+func TestUnsupportedFieldType(t *testing.T) {
+	var s struct {
+		Events chan int
+		Unset  func()
+	}
+	os.Clearenv()
+	if err := Process("env_config", &s); err != nil {
+		t.Errorf("expected no error for unset fields, got %v", err)
+	}
+
+	os.Setenv("ENV_CONFIG_EVENTS", "1")
+	err := Process("env_config", &s)
+	v, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("expected ParseError, got %v", err)
+	}
+	if v.FieldName != "Events" || v.TypeName != "chan int" {
+		t.Errorf("expected Events of type chan int, got %s of type %s", v.FieldName, v.TypeName)
+	}
+	if v.Err != ErrUnsupportedFieldType {
+		t.Errorf("expected %v, got %v", ErrUnsupportedFieldType, v.Err)
+	}
+}
+
+func TestPattern(t *testing.T) {
+	var s struct {
+		Env  string `pattern:"^(dev|staging|prod)$"`
+		Port int    `pattern:"^[0-9]{4}$" default:"8080"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_ENV", "prod")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Env != "prod" || s.Port != 8080 {
+		t.Errorf("expected prod and 8080, got %s and %d", s.Env, s.Port)
+	}
+
+	os.Setenv("ENV_CONFIG_ENV", "production")
+	err := Process("env_config", &s)
+	v, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("expected ParseError, got %v", err)
+	}
+	if v.FieldName != "Env" || v.Value != "production" {
+		t.Errorf("expected Env with its value, got %s %q", v.FieldName, v.Value)
+	}
+	if !strings.Contains(v.Err.Error(), "^(dev|staging|prod)$") {
+		t.Errorf("expected error to name the pattern, got %v", v.Err)
+	}
+	if s.Env != "prod" {
+		t.Errorf("expected field to be left alone, got %s", s.Env)
+	}
+}
+
+func TestTrimValues(t *testing.T) {
+	var s struct {
+		Port    int
+		Debug   bool
+		Ratio   float64
+		Timeout time.Duration
+		IDs     []uint
+		Banner  string
+		Name    string `trim:"true" pattern:"^[a-z]+$"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_PORT", "8080\n")
+	os.Setenv("ENV_CONFIG_DEBUG", "\ttrue")
+	os.Setenv("ENV_CONFIG_RATIO", " 0.5\r\n")
+	os.Setenv("ENV_CONFIG_TIMEOUT", "5s\n")
+	os.Setenv("ENV_CONFIG_IDS", "1, 2,\t3\n")
+	os.Setenv("ENV_CONFIG_BANNER", "  hello\n")
+	os.Setenv("ENV_CONFIG_NAME", "\tservice\n")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Port != 8080 || !s.Debug || s.Ratio != 0.5 || s.Timeout != 5*time.Second {
+		t.Errorf("expected 8080, true, 0.5 and 5s, got %d, %t, %v and %v", s.Port, s.Debug, s.Ratio, s.Timeout)
+	}
+	if len(s.IDs) != 3 || s.IDs[2] != 3 {
+		t.Errorf("expected [1 2 3], got %v", s.IDs)
+	}
+	if s.Banner != "  hello\n" {
+		t.Errorf("expected strings to keep their white space, got %q", s.Banner)
+	}
+	if s.Name != "service" {
+		t.Errorf("expected %q, got %q", "service", s.Name)
+	}
+}
+
+func TestTrimListElements(t *testing.T) {
+	var s struct {
+		Raw     []string
+		Hosts   []string          `trim:"true"`
+		Weights map[string]int    `trim:"true"`
+		Labels  map[string]string `trim:"true" kv_separator:"="`
+		Pair    [2]string         `trim:"true"`
+		Ports   map[int]float64
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_RAW", "a, b")
+	os.Setenv("ENV_CONFIG_HOSTS", "a, b ,\tc\n")
+	os.Setenv("ENV_CONFIG_WEIGHTS", "a : 1, b: 2")
+	os.Setenv("ENV_CONFIG_LABELS", "team = core , tier= web")
+	os.Setenv("ENV_CONFIG_PAIR", " x , y ")
+	os.Setenv("ENV_CONFIG_PORTS", "80: 0.5, 443 :1")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if !reflect.DeepEqual(s.Raw, []string{"a", " b"}) {
+		t.Errorf("expected untagged strings to keep their white space, got %q", s.Raw)
+	}
+	if !reflect.DeepEqual(s.Hosts, []string{"a", "b", "c"}) {
+		t.Errorf("expected [a b c], got %q", s.Hosts)
+	}
+	if !reflect.DeepEqual(s.Weights, map[string]int{"a": 1, "b": 2}) {
+		t.Errorf("expected map[a:1 b:2], got %v", s.Weights)
+	}
+	if !reflect.DeepEqual(s.Labels, map[string]string{"team": "core", "tier": "web"}) {
+		t.Errorf("expected map[team:core tier:web], got %q", s.Labels)
+	}
+	if s.Pair != [2]string{"x", "y"} {
+		t.Errorf("expected [x y], got %q", s.Pair)
+	}
+	if !reflect.DeepEqual(s.Ports, map[int]float64{80: 0.5, 443: 1}) {
+		t.Errorf("expected numbers to be trimmed without the tag, got %v", s.Ports)
+	}
+}
+
+func TestOneOf(t *testing.T) {
+	var s struct {
+		LogLevel string `oneof:"debug info warn error"`
+		Format   string `oneof:"json text" oneof_ignore_case:"true" default:"text"`
+		Port     int    `oneof:"80 443"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_LOGLEVEL", "warn")
+	os.Setenv("ENV_CONFIG_FORMAT", "JSON")
+	os.Setenv("ENV_CONFIG_PORT", "443")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.LogLevel != "warn" || s.Format != "json" || s.Port != 443 {
+		t.Errorf("expected warn, json and 443, got %s, %s and %d", s.LogLevel, s.Format, s.Port)
+	}
+
+	os.Setenv("ENV_CONFIG_LOGLEVEL", "WARN")
+	err := Process("env_config", &s)
+	v, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("expected ParseError, got %v", err)
+	}
+	if v.FieldName != "LogLevel" || v.Err.Error() != "value must be one of debug, info, warn, error" {
+		t.Errorf("expected LogLevel to list the allowed values, got %s: %v", v.FieldName, v.Err)
+	}
+	if s.LogLevel != "warn" {
+		t.Errorf("expected field to be left alone, got %s", s.LogLevel)
+	}
+}
+
+func TestBounds(t *testing.T) {
+	var s struct {
+		Workers int           `min:"1" max:"64"`
+		Ratio   float64       `min:"0" max:"1"`
+		Retries *uint         `max:"5"`
+		Timeout time.Duration `min:"1s"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_WORKERS", "64")
+	os.Setenv("ENV_CONFIG_RATIO", "0.5")
+	os.Setenv("ENV_CONFIG_RETRIES", "3")
+	os.Setenv("ENV_CONFIG_TIMEOUT", "1m")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Workers != 64 || s.Ratio != 0.5 || *s.Retries != 3 || s.Timeout != time.Minute {
+		t.Errorf("unexpected values %+v", s)
+	}
+
+	for key, value := range map[string]string{
+		"ENV_CONFIG_WORKERS": "0",
+		"ENV_CONFIG_RATIO":   "1.5",
+		"ENV_CONFIG_RETRIES": "6",
+		"ENV_CONFIG_TIMEOUT": "500ms",
+	} {
+		os.Clearenv()
+		os.Setenv(key, value)
+		err := Process("env_config", &s)
+		v, ok := err.(*ParseError)
+		if !ok {
+			t.Errorf("%s: expected ParseError, got %v", key, err)
+			continue
+		}
+		if v.KeyName != key {
+			t.Errorf("expected %s, got %s", key, v.KeyName)
+		}
+		if !strings.Contains(v.Err.Error(), "imum") {
+			t.Errorf("%s: expected the limit in the error, got %v", key, v.Err)
+		}
+	}
+	if s.Workers != 64 {
+		t.Errorf("expected out of range value to be rejected, got %d", s.Workers)
+	}
+}
+
+func TestInvalidPattern(t *testing.T) {
+	var s struct {
+		Env string `pattern:"("`
+	}
+	os.Clearenv()
+	err := Process("env_config", &s)
+	if err == nil || !strings.Contains(err.Error(), "invalid pattern for Env") {
+		t.Errorf("expected invalid pattern error, got %v", err)
+	}
+}
+
+func TestSecretRedaction(t *testing.T) {
+	var s struct {
+		Password int `secret:"true"`
+		Port     int
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_PASSWORD", "hunter2")
+	err := Process("env_config", &s)
+	v, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("expected ParseError, got %v", err)
+	}
+	if v.Value != "***" {
+		t.Errorf("expected redacted value, got %q", v.Value)
+	}
+	if strings.Contains(err.Error(), "hunter2") {
+		t.Errorf("expected secret to be redacted, got %q", err.Error())
+	}
+
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_PORT", "http")
+	err = Process("env_config", &s)
+	if !strings.Contains(err.Error(), "http") {
+		t.Errorf("expected value of non-secret field in error, got %q", err.Error())
+	}
+}
+
+func TestSecretRedactionDerivedValues(t *testing.T) {
+	var s struct {
+		Trimmed int            `secret:"true"`
+		List    []int          `secret:"true"`
+		Decoded int            `secret:"true" encoding:"base64"`
+		Map     map[string]int `secret:"true"`
+		Grouped int            `secret:"true" grouped:"true"`
+		Escaped string         `secret:"true" encoding:"url"`
+	}
+	for name, c := range map[string]struct {
+		key, value string
+		cause      error
+	}{
+		"trimmed": {"TRIMMED", "hunter2\n", strconv.ErrSyntax},
+		"element": {"LIST", "1,hunter2", strconv.ErrSyntax},
+		"decoded": {"DECODED", "aHVudGVyMg==", strconv.ErrSyntax},
+		"map key": {"MAP", "hunter2:x", strconv.ErrSyntax},
+		"grouped": {"GROUPED", "1,hunter2", nil},
+		"escaped": {"ESCAPED", "hunter2%zz", nil},
+	} {
+		os.Clearenv()
+		os.Setenv("ENV_CONFIG_"+c.key, c.value)
+		err := Process("env_config", &s)
+		v, ok := err.(*ParseError)
+		if !ok {
+			t.Fatalf("%s: expected ParseError, got %v", name, err)
+		}
+		if strings.Contains(err.Error(), "hunter") || strings.Contains(v.Err.Error(), "hunter") {
+			t.Errorf("%s: expected secret to be redacted, got %q", name, err)
+		}
+		if c.cause != nil && !errors.Is(err, c.cause) {
+			t.Errorf("%s: expected %v to keep the cause %v", name, err, c.cause)
+		}
+		var numErr *strconv.NumError
+		if errors.As(err, &numErr) {
+			t.Errorf("%s: expected the underlying error to be dropped, got %v", name, numErr)
+		}
+	}
+}
+
+func TestEncoding(t *testing.T) {
+	var s struct {
+		Key     []byte `encoding:"base64"`
+		Digest  []byte `encoding:"hex"`
+		Raw     []byte `encoding:"raw"`
+		Default []byte
+		Token   string `encoding:"base64"`
+		Filter  string `encoding:"url"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_KEY", "AAEC/w==")
+	os.Setenv("ENV_CONFIG_DIGEST", "deadbeef")
+	os.Setenv("ENV_CONFIG_RAW", "AAEC/w==")
+	os.Setenv("ENV_CONFIG_DEFAULT", "AAEC/w==")
+	os.Setenv("ENV_CONFIG_TOKEN", "c2VjcmV0")
+	os.Setenv("ENV_CONFIG_FILTER", "a%3D1%26b%3Dtwo+words")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if string(s.Key) != "\x00\x01\x02\xff" {
+		t.Errorf("expected decoded base64, got %v", s.Key)
+	}
+	if string(s.Digest) != "\xde\xad\xbe\xef" {
+		t.Errorf("expected decoded hex, got %v", s.Digest)
+	}
+	if string(s.Raw) != "AAEC/w==" || string(s.Default) != "AAEC/w==" {
+		t.Errorf("expected raw bytes, got %q and %q", s.Raw, s.Default)
+	}
+	if s.Token != "secret" {
+		t.Errorf("expected %s, got %s", "secret", s.Token)
+	}
+	if s.Filter != "a=1&b=two words" {
+		t.Errorf("expected %q, got %q", "a=1&b=two words", s.Filter)
+	}
+
+	os.Setenv("ENV_CONFIG_KEY", "not base64!")
+	err := Process("env_config", &s)
+	v, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("expected ParseError, got %v", err)
+	}
+	if v.FieldName != "Key" || v.Value != "not base64!" {
+		t.Errorf("expected Key with its raw value, got %s %q", v.FieldName, v.Value)
+	}
+
+	os.Setenv("ENV_CONFIG_KEY", "AAEC/w==")
+	os.Setenv("ENV_CONFIG_FILTER", "100%")
+	err = Process("env_config", &s)
+	if v, ok := err.(*ParseError); !ok || v.FieldName != "Filter" {
+		t.Errorf("expected ParseError for Filter, got %v", err)
+	}
+}
+
+func TestJSONEncoding(t *testing.T) {
+	var s struct {
+		Routes map[string][]string `encoding:"json"`
+		Limits struct {
+			Burst int `json:"burst"`
+			Rate  int `json:"rate"`
+		} `encoding:"json"`
+		Hosts  *[]string `encoding:"json" default:"[\"localhost\"]"`
+		Broken []int     `encoding:"json"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_ROUTES", `{"api": ["a", "b"]}`)
+	os.Setenv("ENV_CONFIG_LIMITS", `{"burst": 20, "rate": 5}`)
+	os.Setenv("ENV_CONFIG_LIMITS_BURST", "1")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(s.Routes["api"]) != 2 || s.Routes["api"][1] != "b" {
+		t.Errorf("expected %v, got %v", map[string][]string{"api": {"a", "b"}}, s.Routes)
+	}
+	if s.Limits.Burst != 20 || s.Limits.Rate != 5 {
+		t.Errorf("expected burst 20 and rate 5, got %+v", s.Limits)
+	}
+	if s.Hosts == nil || len(*s.Hosts) != 1 || (*s.Hosts)[0] != "localhost" {
+		t.Errorf("expected [localhost], got %v", s.Hosts)
+	}
+
+	os.Setenv("ENV_CONFIG_BROKEN", "[1, 2")
+	err := Process("env_config", &s)
+	v, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("expected ParseError, got %v", err)
+	}
+	if v.FieldName != "Broken" {
+		t.Errorf("expected %s, got %v", "Broken", v.FieldName)
+	}
+}
+
+func TestMultiSource(t *testing.T) {
+	source := func(env map[string]string) func(string) (string, bool) {
+		return func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		}
+	}
+	lookup := MultiSource(
+		source(map[string]string{"A": "first", "EMPTY": ""}),
+		source(map[string]string{"A": "second", "B": "second", "EMPTY": "second"}),
+	)
+
+	for key, expected := range map[string]string{"A": "first", "B": "second", "EMPTY": ""} {
+		if v, ok := lookup(key); !ok || v != expected {
+			t.Errorf("%s: expected %q, got %q (%v)", key, expected, v, ok)
+		}
+	}
+	if _, ok := lookup("C"); ok {
+		t.Error("expected C to be missing")
+	}
+
+	var s Specification
+	err := ProcessWith("env_config", &s, MultiSource(
+		source(map[string]string{"ENV_CONFIG_PORT": "8080"}),
+		source(map[string]string{"ENV_CONFIG_PORT": "1", "ENV_CONFIG_REQUIREDVAR": "foo"}),
+	))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Port != 8080 || s.RequiredVar != "foo" {
+		t.Errorf("expected 8080 and foo, got %d and %s", s.Port, s.RequiredVar)
+	}
+}
+
+func TestProcessContext(t *testing.T) {
+	var s Specification
+	env := map[string]string{
+		"ENV_CONFIG_PORT":        "8080",
+		"ENV_CONFIG_REQUIREDVAR": "foo",
+	}
+	lookup := func(ctx context.Context, key string) (string, bool, error) {
+		v, ok := env[key]
+		return v, ok, nil
+	}
+	if err := ProcessContext(context.Background(), "env_config", &s, lookup); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Port != 8080 || s.RequiredVar != "foo" {
+		t.Errorf("expected 8080 and foo, got %d and %s", s.Port, s.RequiredVar)
+	}
+
+	errBackend := errors.New("backend unavailable")
+	err := ProcessContext(context.Background(), "env_config", &s, func(ctx context.Context, key string) (string, bool, error) {
+		return "", false, errBackend
+	})
+	if err != errBackend {
+		t.Errorf("expected %v, got %v", errBackend, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	err = ProcessContext(ctx, "env_config", &s, func(ctx context.Context, key string) (string, bool, error) {
+		calls++
+		cancel()
+		return "", false, nil
+	})
+	if err != context.Canceled {
+		t.Errorf("expected %v, got %v", context.Canceled, err)
+	}
+	if calls != 1 {
+		t.Errorf("expected processing to stop after cancellation, got %d lookups", calls)
+	}
+}
+
+func TestErrInvalidSpecification(t *testing.T) {
+	m := make(map[string]string)
+	err := Process("env_config", &m)
+	if err != ErrInvalidSpecification {
+		t.Errorf("expected %v, got %v", ErrInvalidSpecification, err)
+	}
+}
+
+func TestInvalidSpecifications(t *testing.T) {
+	var nilSpec *Specification
+	n := 1
+	specs := map[string]interface{}{
+		"nil":                    nil,
+		"nil pointer":            nilSpec,
+		"non-pointer struct":     Specification{},
+		"pointer to non-struct":  &n,
+		"pointer to pointer":     &nilSpec,
+		"non-pointer non-struct": n,
+	}
+	lookup := func(string) (string, bool) { return "", false }
+	for name, spec := range specs {
+		checks := map[string]error{
+			"Process":     Process("env_config", spec),
+			"ProcessWith": ProcessWith("env_config", spec, lookup),
+			"ProcessAll":  ProcessAll("env_config", spec),
+			"ProcessContext": ProcessContext(context.Background(), "env_config", spec, func(context.Context, string) (string, bool, error) {
+				return "", false, nil
+			}),
+			"CheckDisallowed": CheckDisallowed("env_config", spec),
+			"Usagef":          Usagef("env_config", spec, ioutil.Discard, DefaultListFormat),
+		}
+		_, checks["Resolve"] = Resolve("env_config", spec)
+		_, checks["Sources"] = Sources("env_config", spec)
+		_, checks["NewProcessor"] = NewProcessor(spec)
+		for fn, err := range checks {
+			if err != ErrInvalidSpecification {
+				t.Errorf("%s with a %s: expected %v, got %v", fn, name, ErrInvalidSpecification, err)
+			}
+		}
+	}
+}
+
+func TestUnsetVars(t *testing.T) {
+	var s Specification
+	os.Clearenv()
+	os.Setenv("USER", "foo")
+	os.Setenv("ENV_CONFIG_REQUIREDVAR", "foo")
+	if err := Process("env_config", &s); err != nil {
+		t.Error(err.Error())
+	}
+
+	// If the var is not defined the non-prefixed version should not be used
+	// unless the struct tag says so
+	if s.User != "" {
+		t.Errorf("expected %q, got %q", "", s.User)
+	}
+}
+
+func TestAlternateVarNames(t *testing.T) {
+	var s Specification
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_MULTI_WORD_VAR", "foo")
+	os.Setenv("ENV_CONFIG_MULTI_WORD_VAR_WITH_ALT", "bar")
+	os.Setenv("ENV_CONFIG_MULTI_WORD_VAR_WITH_LOWER_CASE_ALT", "baz")
+	os.Setenv("ENV_CONFIG_REQUIREDVAR", "foo")
+	if err := Process("env_config", &s); err != nil {
+		t.Error(err.Error())
+	}
+
+	// Setting the alt version of the var in the environment has no effect if
+	// the struct tag is not supplied
+	if s.MultiWordVar != "" {
+		t.Errorf("expected %q, got %q", "", s.MultiWordVar)
+	}
+
+	// Setting the alt version of the var in the environment correctly sets
+	// the value if the struct tag IS supplied
+	if s.MultiWordVarWithAlt != "bar" {
+		t.Errorf("expected %q, got %q", "bar", s.MultiWordVarWithAlt)
+	}
+
+	// Alt value is not case sensitive and is treated as all uppercase
+	if s.MultiWordVarWithLowerCaseAlt != "baz" {
+		t.Errorf("expected %q, got %q", "baz", s.MultiWordVarWithLowerCaseAlt)
+	}
+}
+
+func TestSplitWords(t *testing.T) {
+	var s struct {
+		MaxIdleConns int    `split_words:"true"`
+		HTTPPort     int    `split_words:"true"`
+		MyURLValue   string `split_words:"true"`
+		UserID       string `split_words:"true"`
+		ID           string `split_words:"true"`
+		NotSplit     string
+	}
+	infos, err := gatherInfo("env_config", &s)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	expected := []string{
+		"ENV_CONFIG_MAX_IDLE_CONNS",
+		"ENV_CONFIG_HTTP_PORT",
+		"ENV_CONFIG_MY_URL_VALUE",
+		"ENV_CONFIG_USER_ID",
+		"ENV_CONFIG_ID",
+		"ENV_CONFIG_NOTSPLIT",
+	}
+	if len(infos) != len(expected) {
+		t.Fatalf("expected %d keys, got %d", len(expected), len(infos))
+	}
+	for i, info := range infos {
+		if info.Key != expected[i] {
+			t.Errorf("%s: expected %s, got %s", info.Name, expected[i], info.Key)
+		}
+	}
+}
+
+func TestExactVarNames(t *testing.T) {
+	var s struct {
+		HTTPProxy string `envconfig:"http_proxy,exact"`
+		Mixed     string `envconfig:",exact"`
+		Required  string `envconfig:"lower_required,exact" required:"true"`
+	}
+	os.Clearenv()
+	os.Setenv("HTTP_PROXY", "upper")
+	os.Setenv("http_proxy", "lower")
+	os.Setenv("env_config_http_proxy", "prefixed")
+	os.Setenv("env_config_Mixed", "mixed")
+	os.Setenv("env_config_lower_required", "set")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.HTTPProxy != "prefixed" {
+		t.Errorf("expected %q, got %q", "prefixed", s.HTTPProxy)
+	}
+	if s.Mixed != "mixed" {
+		t.Errorf("expected %q, got %q", "mixed", s.Mixed)
+	}
+
+	// exact keys do not fall back to the bare tag name
+	os.Unsetenv("env_config_http_proxy")
+	s.HTTPProxy = ""
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.HTTPProxy != "" {
+		t.Errorf("expected %q, got %q", "", s.HTTPProxy)
+	}
+
+	var flat struct {
+		HTTPProxy string `envconfig:"http_proxy,exact"`
+	}
+	if err := Process("", &flat); err != nil {
+		t.Fatal(err.Error())
+	}
+	if flat.HTTPProxy != "lower" {
+		t.Errorf("expected %q, got %q", "lower", flat.HTTPProxy)
+	}
+
+	os.Unsetenv("env_config_lower_required")
+	err := Process("env_config", &s)
+	if want := "required key env_config_lower_required missing value"; err == nil || err.Error() != want {
+		t.Errorf("expected %q, got %v", want, err)
+	}
+}
+
+func TestNoPrefixVarNames(t *testing.T) {
+	var s struct {
+		LogLevel string `envconfig:"LOG_LEVEL,noprefix"`
+		Region   string `envconfig:"region,noprefix" default:"eu"`
+		Token    string `envconfig:"TOKEN,noprefix" required:"true"`
+	}
+	os.Clearenv()
+	os.Setenv("LOG_LEVEL", "debug")
+	os.Setenv("ENV_CONFIG_LOG_LEVEL", "ignored")
+	os.Setenv("TOKEN", "secret")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.LogLevel != "debug" {
+		t.Errorf("expected %q, got %q", "debug", s.LogLevel)
+	}
+	if s.Region != "eu" {
+		t.Errorf("expected %q, got %q", "eu", s.Region)
+	}
+
+	os.Unsetenv("TOKEN")
+	os.Setenv("ENV_CONFIG_TOKEN", "ignored")
+	err := Process("env_config", &s)
+	if want := "required key TOKEN missing value"; err == nil || err.Error() != want {
+		t.Errorf("expected %q, got %v", want, err)
+	}
+}
+
+func TestDashTagIgnored(t *testing.T) {
+	var s struct {
+		Computed string `envconfig:"-"`
+		Nested   struct {
+			Value string
+		} `envconfig:"-"`
+		Port int
+	}
+	s.Computed = "computed"
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_COMPUTED", "overwritten")
+	os.Setenv("ENV_CONFIG_NESTED_VALUE", "overwritten")
+	os.Setenv("ENV_CONFIG_PORT", "8080")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Computed != "computed" {
+		t.Errorf("expected %q, got %q", "computed", s.Computed)
+	}
+	if s.Nested.Value != "" {
+		t.Errorf("expected empty string, got %q", s.Nested.Value)
+	}
+	if s.Port != 8080 {
+		t.Errorf("expected %d, got %d", 8080, s.Port)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	var s struct {
+		Port     int
+		Host     string `default:"localhost"`
+		Password string `secret:"true"`
+		Broker   string `envconfig:"BROKER"`
+		Unset    string
+		Server   *struct {
+			Name string
+		}
+	}
+	s.Port = 80
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_PORT", "8080")
+	os.Setenv("ENV_CONFIG_PASSWORD", "hunter2")
+	os.Setenv("BROKER", "127.0.0.1")
+	os.Setenv("ENV_CONFIG_SERVER_NAME", "api")
+
+	resolved, err := Resolve("env_config", &s)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	expected := map[string]string{
+		"ENV_CONFIG_PORT":        "8080",
+		"ENV_CONFIG_HOST":        "localhost",
+		"ENV_CONFIG_PASSWORD":    "***",
+		"ENV_CONFIG_BROKER":      "127.0.0.1",
+		"ENV_CONFIG_SERVER_NAME": "api",
+	}
+	if len(resolved) != len(expected) {
+		t.Errorf("expected %v, got %v", expected, resolved)
+	}
+	for k, v := range expected {
+		if resolved[k] != v {
+			t.Errorf("%s: expected %q, got %q", k, v, resolved[k])
+		}
+	}
+	if s.Port != 80 || s.Host != "" || s.Server != nil {
+		t.Errorf("expected spec to be untouched, got %+v", s)
+	}
+
+	os.Setenv("ENV_CONFIG_PORT", "http")
+	if _, err := Resolve("env_config", &s); err == nil {
+		t.Error("expected a parse error")
+	}
+}
+
+func TestNegatedBool(t *testing.T) {
+	var s struct {
+		Color   bool  `envconfig:"NO_COLOR" negate:"true" default:"false"`
+		Proxy   *bool `envconfig:"NO_PROXY" negate:"true" bool:"lenient"`
+		Verbose bool  `negate:"true"`
+	}
+	os.Clearenv()
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if !s.Color {
+		t.Error("expected Color to be true while NO_COLOR is unset")
+	}
+	if s.Proxy != nil || s.Verbose {
+		t.Errorf("expected <nil> and false for unset variables, got %v and %v", s.Proxy, s.Verbose)
+	}
+
+	os.Setenv("ENV_CONFIG_NO_COLOR", "1")
+	os.Setenv("ENV_CONFIG_NO_PROXY", "yes")
+	os.Setenv("ENV_CONFIG_VERBOSE", "false")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Color {
+		t.Error("expected NO_COLOR=1 to set Color to false")
+	}
+	if s.Proxy == nil || *s.Proxy {
+		t.Errorf("expected pointer to false, got %v", s.Proxy)
+	}
+	if !s.Verbose {
+		t.Error("expected VERBOSE=false to set Verbose to true")
+	}
+}
+
+func TestLenientBool(t *testing.T) {
+	var s struct {
+		Yes      bool   `bool:"lenient"`
+		Off      bool   `bool:"lenient"`
+		Enabled  bool   `bool:"lenient"`
+		Standard bool   `bool:"lenient"`
+		Flags    []bool `bool:"lenient"`
+		Strict   bool
+	}
+	s.Off = true
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_YES", "YES")
+	os.Setenv("ENV_CONFIG_OFF", "off")
+	os.Setenv("ENV_CONFIG_ENABLED", "Enabled")
+	os.Setenv("ENV_CONFIG_STANDARD", "1")
+	os.Setenv("ENV_CONFIG_FLAGS", "on,no,true")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if !s.Yes || s.Off || !s.Enabled || !s.Standard {
+		t.Errorf("expected true, false, true, true, got %+v", s)
+	}
+	if len(s.Flags) != 3 || !s.Flags[0] || s.Flags[1] || !s.Flags[2] {
+		t.Errorf("expected [true false true], got %v", s.Flags)
+	}
+
+	os.Setenv("ENV_CONFIG_STRICT", "yes")
+	err := Process("env_config", &s)
+	if v, ok := err.(*ParseError); !ok || v.FieldName != "Strict" {
+		t.Errorf("expected ParseError for Strict, got %v", err)
+	}
+
+	os.Setenv("ENV_CONFIG_STRICT", "true")
+	os.Setenv("ENV_CONFIG_YES", "sure")
+	err = Process("env_config", &s)
+	if v, ok := err.(*ParseError); !ok || v.FieldName != "Yes" {
+		t.Errorf("expected ParseError for Yes, got %v", err)
+	}
+}
+
+func TestPrefixes(t *testing.T) {
+	var s struct {
+		Port   int
+		Server struct {
+			Host string
+		}
+	}
+	for _, tc := range []struct {
+		prefix string
+		port   string
+		host   string
+	}{
+		{"", "PORT", "SERVER_HOST"},
+		{" \t", "PORT", "SERVER_HOST"},
+		{"myapp", "MYAPP_PORT", "MYAPP_SERVER_HOST"},
+		{" myapp ", "MYAPP_PORT", "MYAPP_SERVER_HOST"},
+	} {
+		infos, err := gatherInfo(tc.prefix, &s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if infos[0].Key != tc.port || infos[1].Key != tc.host {
+			t.Errorf("prefix %q: expected %s and %s, got %s and %s", tc.prefix, tc.port, tc.host, infos[0].Key, infos[1].Key)
+		}
+	}
+
+	os.Clearenv()
+	os.Setenv("PORT", "8080")
+	os.Setenv("_PORT", "9090")
+	if err := Process("", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Port != 8080 {
+		t.Errorf("expected %d, got %d", 8080, s.Port)
+	}
+}
+
+func TestFileVars(t *testing.T) {
+	var s struct {
+		Password string `required:"true"`
+		Host     string `envconfig:"DB_HOST"`
+		Port     int
+	}
+	path := writeDotEnv(t, "hunter2\n")
+	defer os.Remove(path)
+
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_PASSWORD_FILE", path)
+	os.Setenv("DB_HOST_FILE", path)
+	os.Setenv("ENV_CONFIG_PORT", "8080")
+	os.Setenv("ENV_CONFIG_PORT_FILE", "/does/not/exist")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Password != "hunter2" {
+		t.Errorf("expected %q, got %q", "hunter2", s.Password)
+	}
+	if s.Host != "hunter2" {
+		t.Errorf("expected %q, got %q", "hunter2", s.Host)
+	}
+	if s.Port != 8080 {
+		t.Errorf("expected %d, got %d", 8080, s.Port)
+	}
+
+	os.Setenv("ENV_CONFIG_PASSWORD", "direct")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Password != "direct" {
+		t.Errorf("expected %q, got %q", "direct", s.Password)
+	}
+
+	os.Unsetenv("ENV_CONFIG_PASSWORD")
+	os.Setenv("ENV_CONFIG_PASSWORD_FILE", "/does/not/exist")
+	err := Process("env_config", &s)
+	if err == nil || !strings.Contains(err.Error(), "ENV_CONFIG_PASSWORD_FILE") || !strings.Contains(err.Error(), "/does/not/exist") {
+		t.Errorf("expected an error naming the variable and path, got %v", err)
+	}
+}
+
+func TestCombinedTagOptions(t *testing.T) {
+	var s struct {
+		Port    int    `envconfig:"PORT,default=8080,description=listen port"`
+		Host    string `envconfig:",default=localhost" default:"example.com"`
+		Token   string `envconfig:"TOKEN,required"`
+		Skipped string `envconfig:",ignored"`
+		LogDir  string `envconfig:",split_words,default=/var/log"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_SKIPPED", "set")
+	err := Process("env_config", &s)
+	if want := "required key TOKEN missing value"; err == nil || err.Error() != want {
+		t.Errorf("expected %q, got %v", want, err)
+	}
+
+	os.Setenv("ENV_CONFIG_TOKEN", "secret")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Port != 8080 {
+		t.Errorf("expected %d, got %d", 8080, s.Port)
+	}
+	if s.Host != "localhost" {
+		t.Errorf("expected %q, got %q", "localhost", s.Host)
+	}
+	if s.Skipped != "" {
+		t.Errorf("expected empty string, got %q", s.Skipped)
+	}
+	if s.LogDir != "/var/log" {
+		t.Errorf("expected %q, got %q", "/var/log", s.LogDir)
+	}
+
+	infos, err := gatherInfo("env_config", &s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if desc := infos[0].description(); desc != "listen port" {
+		t.Errorf("expected %q, got %q", "listen port", desc)
+	}
+	if infos[3].Key != "ENV_CONFIG_LOG_DIR" {
+		t.Errorf("expected %s, got %s", "ENV_CONFIG_LOG_DIR", infos[3].Key)
+	}
+}
+
+type tlsSpec struct {
+	Hostname string
+	TLS      bool
+	CertPath string
+	calls    []string
+}
+
+func (s *tlsSpec) PostProcess(fieldName string) error {
+	s.calls = append(s.calls, fieldName)
+	switch fieldName {
+	case "Hostname":
+		s.Hostname = strings.ToLower(s.Hostname)
+	case "CertPath":
+		if s.TLS && s.CertPath == "" {
+			return errors.New("CertPath is required when TLS is enabled")
+		}
+	}
+	return nil
+}
+
+func TestPostProcessor(t *testing.T) {
+	var s tlsSpec
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_HOSTNAME", "Example.COM")
+	os.Setenv("ENV_CONFIG_TLS", "true")
+	os.Setenv("ENV_CONFIG_CERTPATH", "/etc/cert.pem")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Hostname != "example.com" {
+		t.Errorf("expected %q, got %q", "example.com", s.Hostname)
+	}
+	if got := strings.Join(s.calls, ","); got != "Hostname,TLS,CertPath" {
+		t.Errorf("expected fields in declaration order, got %s", got)
+	}
+
+	s = tlsSpec{}
+	os.Unsetenv("ENV_CONFIG_CERTPATH")
+	err := Process("env_config", &s)
+	if want := "CertPath is required when TLS is enabled"; err == nil || err.Error() != want {
+		t.Errorf("expected %q, got %v", want, err)
+	}
+	if _, err := Resolve("env_config", &s); err == nil {
+		t.Error("expected Resolve to report the post processing error")
+	}
+}
+
+type windowSpec struct {
+	Start     int
+	End       int
+	Required  string `required:"true"`
+	validated int
+}
+
+func (s *windowSpec) Validate() error {
+	s.validated++
+	if s.Start > s.End {
+		return fmt.Errorf("start %d is after end %d", s.Start, s.End)
+	}
+	return nil
+}
+
+func TestValidator(t *testing.T) {
+	var s windowSpec
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_START", "1")
+	os.Setenv("ENV_CONFIG_END", "5")
+	os.Setenv("ENV_CONFIG_REQUIRED", "x")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.validated != 1 {
+		t.Errorf("expected Validate to be called once, got %d", s.validated)
+	}
+
+	s = windowSpec{}
+	os.Setenv("ENV_CONFIG_START", "7")
+	err := Process("env_config", &s)
+	if want := "start 7 is after end 5"; err == nil || err.Error() != want {
+		t.Errorf("expected %q, got %v", want, err)
+	}
+	if err := ProcessAll("env_config", &s); err == nil {
+		t.Error("expected ProcessAll to report the validation error")
+	}
+
+	s = windowSpec{}
+	os.Unsetenv("ENV_CONFIG_REQUIRED")
+	if err := Process("env_config", &s); err == nil || !strings.Contains(err.Error(), "required key") {
+		t.Errorf("expected a required key error, got %v", err)
+	}
+	if s.validated != 0 {
+		t.Errorf("expected Validate not to run after a failed required check, got %d calls", s.validated)
+	}
+}
+
+func TestExpandValues(t *testing.T) {
+	var s struct {
+		DataDir  string   `expand:"true"`
+		Paths    []string `expand:"true"`
+		Password string
+	}
+	os.Clearenv()
+	os.Setenv("HOME", "/home/gopher")
+	os.Setenv("ENV_CONFIG_DATADIR", "${HOME}/data")
+	os.Setenv("ENV_CONFIG_PATHS", "$HOME/bin,/usr/${UNDEFINED}bin")
+	os.Setenv("ENV_CONFIG_PASSWORD", "pa$$word")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.DataDir != "/home/gopher/data" {
+		t.Errorf("expected %q, got %q", "/home/gopher/data", s.DataDir)
+	}
+	if len(s.Paths) != 2 || s.Paths[0] != "/home/gopher/bin" || s.Paths[1] != "/usr/bin" {
+		t.Errorf("expected [/home/gopher/bin /usr/bin], got %v", s.Paths)
+	}
+	if s.Password != "pa$$word" {
+		t.Errorf("expected values to be literal without the tag, got %q", s.Password)
+	}
+
+	env := map[string]string{"ENV_CONFIG_DATADIR": "$ROOT/data", "ROOT": "/srv"}
+	err := ProcessWith("env_config", &s, func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.DataDir != "/srv/data" {
+		t.Errorf("expected references to be expanded through the lookup, got %q", s.DataDir)
+	}
+}
+
+func TestTemplate(t *testing.T) {
+	var s struct {
+		Addr   string `template:"${DB_HOST}:${DB_PORT}"`
+		DSN    string `template:"postgres://${DB_USER}@${DB_HOST}/${DB_NAME}" template_strict:"true"`
+		Backup string `template:"${BACKUP_HOST}" default:"none"`
+		Port   int    `template:"${DB_PORT}"`
+	}
+	os.Clearenv()
+	os.Setenv("DB_HOST", "db.local")
+	os.Setenv("DB_PORT", "5432")
+	os.Setenv("DB_USER", "app")
+	os.Setenv("DB_NAME", "main")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Addr != "db.local:5432" || s.DSN != "postgres://app@db.local/main" || s.Port != 5432 {
+		t.Errorf("expected the templates to be expanded, got %+v", s)
+	}
+	if s.Backup != "none" {
+		t.Errorf("expected the default without any referenced variable, got %q", s.Backup)
+	}
+
+	os.Setenv("ENV_CONFIG_ADDR", "override:1")
+	os.Unsetenv("DB_PORT")
+	os.Unsetenv("DB_NAME")
+	err := Process("env_config", &s)
+	if want := "envconfig: template of ENV_CONFIG_DSN refers to undefined DB_NAME"; err == nil || err.Error() != want {
+		t.Errorf("expected %q, got %v", want, err)
+	}
+	if s.Addr != "override:1" {
+		t.Errorf("expected the key of the field to win over the template, got %q", s.Addr)
+	}
+}
+
+func TestDefaultFuncs(t *testing.T) {
+	RegisterDefaultFunc("test_workers", func() (string, error) { return "12", nil })
+	RegisterDefaultFunc("test_failing", func() (string, error) { return "", errors.New("no host name") })
+	var s struct {
+		Workers int    `default:"$fn:test_workers"`
+		Host    string `default:"$fn:test_failing"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_HOST", "set")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Workers != 12 {
+		t.Errorf("expected %d, got %d", 12, s.Workers)
+	}
+
+	os.Unsetenv("ENV_CONFIG_HOST")
+	err := Process("env_config", &s)
+	if want := "envconfig: default of ENV_CONFIG_HOST: no host name"; err == nil || err.Error() != want {
+		t.Errorf("expected %q, got %v", want, err)
+	}
+
+	var unknown struct {
+		Dir string `default:"$fn:test_unregistered"`
+	}
+	if err := Process("env_config", &unknown); err == nil || !strings.Contains(err.Error(), "unknown function test_unregistered") {
+		t.Errorf("expected an unknown function error, got %v", err)
+	}
+}
+
+func TestCollectionDefaults(t *testing.T) {
+	var s struct {
+		Hosts   []string          `envconfig:"HOSTS" default:"localhost"`
+		Ports   []int             `default:"80;443" separator:";"`
+		Weights map[string]int    `default:"a:1,b:2"`
+		Labels  map[string]string `default:""`
+		Tags    []string          `default:""`
+		Extra   *[]string         `default:""`
+		Unset   []string
+	}
+	os.Clearenv()
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(s.Hosts) != 1 || s.Hosts[0] != "localhost" {
+		t.Errorf("expected [localhost], got %v", s.Hosts)
+	}
+	if len(s.Ports) != 2 || s.Ports[0] != 80 || s.Ports[1] != 443 {
+		t.Errorf("expected [80 443], got %v", s.Ports)
+	}
+	if len(s.Weights) != 2 || s.Weights["a"] != 1 || s.Weights["b"] != 2 {
+		t.Errorf("expected map[a:1 b:2], got %v", s.Weights)
+	}
+	if s.Labels == nil || len(s.Labels) != 0 {
+		t.Errorf("expected an empty map, got %#v", s.Labels)
+	}
+	if s.Tags == nil || len(s.Tags) != 0 {
+		t.Errorf("expected an empty slice, got %#v", s.Tags)
+	}
+	if s.Extra == nil || len(*s.Extra) != 0 {
+		t.Errorf("expected a pointer to an empty slice, got %#v", s.Extra)
+	}
+	if s.Unset != nil {
+		t.Errorf("expected <nil>, got %#v", s.Unset)
+	}
+
+	os.Setenv("ENV_CONFIG_PORTS", "8080")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(s.Ports) != 1 || s.Ports[0] != 8080 {
+		t.Errorf("expected [8080], got %v", s.Ports)
+	}
+}
+
+func TestDurationElements(t *testing.T) {
+	var s struct {
+		Backoff  []time.Duration
+		Timeouts map[string]time.Duration
+		Windows  [2]time.Duration
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_BACKOFF", "1s,2m,3h")
+	os.Setenv("ENV_CONFIG_TIMEOUTS", "read:5s,write:1m30s")
+	os.Setenv("ENV_CONFIG_WINDOWS", "10ms,1s")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(s.Backoff) != 3 || s.Backoff[0] != time.Second || s.Backoff[1] != 2*time.Minute || s.Backoff[2] != 3*time.Hour {
+		t.Errorf("expected [1s 2m0s 3h0m0s], got %v", s.Backoff)
+	}
+	if s.Timeouts["read"] != 5*time.Second || s.Timeouts["write"] != 90*time.Second {
+		t.Errorf("expected map[read:5s write:1m30s], got %v", s.Timeouts)
+	}
+	if s.Windows != [2]time.Duration{10 * time.Millisecond, time.Second} {
+		t.Errorf("expected [10ms 1s], got %v", s.Windows)
+	}
+
+	os.Setenv("ENV_CONFIG_BACKOFF", "1s,2")
+	err := Process("env_config", &s)
+	if v, ok := err.(*ParseError); !ok || !strings.HasPrefix(v.Err.Error(), "element 1: ") {
+		t.Errorf("expected ParseError for element 1, got %v", err)
+	}
+}
+
+func TestSetField(t *testing.T) {
+	var s struct {
+		Ports   []int `separator:";"`
+		Timeout time.Duration
+		Size    int64 `unit:"bytes"`
+	}
+	v := reflect.ValueOf(&s).Elem()
+	if err := SetField(v.Field(0), "80;443", v.Type().Field(0).Tag); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetField(v.Field(1), "1m", v.Type().Field(1).Tag); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetField(v.Field(2), "2KiB", v.Type().Field(2).Tag); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Ports) != 2 || s.Ports[1] != 443 || s.Timeout != time.Minute || s.Size != 2048 {
+		t.Errorf("expected [80 443], 1m0s and 2048, got %v, %v and %d", s.Ports, s.Timeout, s.Size)
+	}
+
+	if err := SetField(v.Field(1), "soon", ""); err == nil {
+		t.Error("expected a conversion error")
+	}
+	if err := SetField(reflect.ValueOf(s).Field(1), "1m", ""); err == nil {
+		t.Error("expected an error for a field that is not settable")
+	}
+
+	var enc struct {
+		Key    []byte `encoding:"base64"`
+		Digest []byte `encoding:"hex"`
+		Filter string `encoding:"url"`
+	}
+	e := reflect.ValueOf(&enc).Elem()
+	for i, value := range []string{"aGVsbG8=", "deadbeef", "a%3D1"} {
+		if err := SetField(e.Field(i), value, e.Type().Field(i).Tag); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if string(enc.Key) != "hello" || string(enc.Digest) != "\xde\xad\xbe\xef" || enc.Filter != "a=1" {
+		t.Errorf("expected the values to be decoded, got %q, %q and %q", enc.Key, enc.Digest, enc.Filter)
+	}
+	if err := SetField(e.Field(0), "not base64!", e.Type().Field(0).Tag); err == nil {
+		t.Error("expected a decoding error")
+	}
+}
+
+func TestDefaultOnEmpty(t *testing.T) {
+	type spec struct {
+		Keep     string `default:"fallback"`
+		Fallback string `default:"fallback" default_on_empty:"true"`
+		NoDef    string `default_on_empty:"true" required:"true"`
+	}
+	for _, tc := range []struct {
+		name     string
+		value    *string
+		keep     string
+		fallback string
+	}{
+		{"unset", nil, "fallback", "fallback"},
+		{"empty", new(string), "", "fallback"},
+		{"set", func() *string { v := "value"; return &v }(), "value", "value"},
+	} {
+		var s spec
+		os.Clearenv()
+		os.Setenv("ENV_CONFIG_NODEF", "")
+		if tc.value != nil {
+			os.Setenv("ENV_CONFIG_KEEP", *tc.value)
+			os.Setenv("ENV_CONFIG_FALLBACK", *tc.value)
+		}
+		if err := Process("env_config", &s); err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if s.Keep != tc.keep {
+			t.Errorf("%s: expected %q, got %q", tc.name, tc.keep, s.Keep)
+		}
+		if s.Fallback != tc.fallback {
+			t.Errorf("%s: expected %q, got %q", tc.name, tc.fallback, s.Fallback)
+		}
+	}
+}
+
+func TestIntBase(t *testing.T) {
+	var s struct {
+		Auto    int
+		Decimal int    `base:"10"`
+		Mode    uint32 `base:"8"`
+		Mask    []int  `base:"16"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_AUTO", "0755")
+	os.Setenv("ENV_CONFIG_DECIMAL", "0755")
+	os.Setenv("ENV_CONFIG_MODE", "755")
+	os.Setenv("ENV_CONFIG_MASK", "ff,10")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Auto != 0755 {
+		t.Errorf("expected %d, got %d", 0755, s.Auto)
+	}
+	if s.Decimal != 755 {
+		t.Errorf("expected %d, got %d", 755, s.Decimal)
+	}
+	if s.Mode != 0755 {
+		t.Errorf("expected %o, got %o", 0755, s.Mode)
+	}
+	if len(s.Mask) != 2 || s.Mask[0] != 255 || s.Mask[1] != 16 {
+		t.Errorf("expected [255 16], got %v", s.Mask)
+	}
+
+	os.Setenv("ENV_CONFIG_MODE", "0x1ff")
+	if _, ok := Process("env_config", &s).(*ParseError); !ok {
+		t.Error("expected ParseError for a hexadecimal value in base 8")
+	}
+
+	var invalid struct {
+		Port int `base:"1"`
+	}
+	err := Process("env_config", &invalid)
+	if want := `envconfig: invalid base for Port: "1" is not 0 or between 2 and 36`; err == nil || err.Error() != want {
+		t.Errorf("expected %q, got %v", want, err)
+	}
+}
+
+func TestFileMode(t *testing.T) {
+	var s struct {
+		FileMode os.FileMode
+		DirMode  os.FileMode
+		Umask    os.FileMode
+		Decimal  os.FileMode `base:"10"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_FILEMODE", "0644")
+	os.Setenv("ENV_CONFIG_DIRMODE", "755")
+	os.Setenv("ENV_CONFIG_UMASK", "0o022")
+	os.Setenv("ENV_CONFIG_DECIMAL", "420")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.FileMode != 0644 {
+		t.Errorf("expected %v, got %v", os.FileMode(0644), s.FileMode)
+	}
+	if s.DirMode != 0755 {
+		t.Errorf("expected %v, got %v", os.FileMode(0755), s.DirMode)
+	}
+	if s.Umask != 0022 {
+		t.Errorf("expected %v, got %v", os.FileMode(0022), s.Umask)
+	}
+	if s.Decimal != 0644 {
+		t.Errorf("expected %v, got %v", os.FileMode(0644), s.Decimal)
+	}
+
+	for _, value := range []string{"rw-r--r--", "0855"} {
+		os.Setenv("ENV_CONFIG_FILEMODE", value)
+		err := Process("env_config", &s)
+		if v, ok := err.(*ParseError); !ok || v.FieldName != "FileMode" {
+			t.Errorf("%s: expected ParseError for FileMode, got %v", value, err)
+		}
+	}
+}
+
+func TestBigNumbers(t *testing.T) {
+	var s struct {
+		Limit  *big.Int
+		Mask   big.Int
+		Rate   *big.Float
+		Unused *big.Int
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_LIMIT", "123456789012345678901234567890")
+	os.Setenv("ENV_CONFIG_MASK", "0xffffffffffffffffffff")
+	os.Setenv("ENV_CONFIG_RATE", "0.000000000000000000012345")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Limit.String() != "123456789012345678901234567890" {
+		t.Errorf("expected %v, got %v", "123456789012345678901234567890", s.Limit)
+	}
+	if want := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 80), big.NewInt(1)); s.Mask.Cmp(want) != 0 {
+		t.Errorf("expected %v, got %v", want, &s.Mask)
+	}
+	if got := s.Rate.Text('e', 4); got != "1.2345e-20" {
+		t.Errorf("expected %v, got %v", "1.2345e-20", got)
+	}
+	if s.Unused != nil {
+		t.Errorf("expected <nil>, got %v", s.Unused)
+	}
+
+	var precise struct {
+		Ratio big.Float
+		Fixed big.Float
+	}
+	precise.Fixed.SetPrec(32)
+	const ratio = "0.1234567890123456789012345678901234567890"
+	os.Setenv("ENV_CONFIG_RATIO", ratio)
+	os.Setenv("ENV_CONFIG_FIXED", ratio)
+	if err := Process("env_config", &precise); err != nil {
+		t.Fatal(err.Error())
+	}
+	if got := precise.Ratio.Text('f', 40); got != ratio {
+		t.Errorf("expected %v, got %v", ratio, got)
+	}
+	if precise.Fixed.Prec() != 32 {
+		t.Errorf("expected the precision of the field to be kept, got %d", precise.Fixed.Prec())
+	}
+
+	os.Setenv("ENV_CONFIG_LIMIT", "12ab")
+	err := Process("env_config", &s)
+	if v, ok := err.(*ParseError); !ok || v.FieldName != "Limit" {
+		t.Errorf("expected ParseError for Limit, got %v", err)
+	}
+	os.Setenv("ENV_CONFIG_LIMIT", "1")
+
+	os.Setenv("ENV_CONFIG_RATE", "1.2.3")
+	err = Process("env_config", &s)
+	if v, ok := err.(*ParseError); !ok || v.FieldName != "Rate" {
+		t.Errorf("expected ParseError for Rate, got %v", err)
+	}
+}
+
+func TestNetworkFields(t *testing.T) {
+	var s struct {
+		BindIP     net.IP
+		AllowedNet net.IPNet
+		Trusted    []net.IPNet
+		Optional   *net.IPNet
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_BINDIP", "10.1.2.3")
+	os.Setenv("ENV_CONFIG_ALLOWEDNET", "10.0.0.0/8")
+	os.Setenv("ENV_CONFIG_TRUSTED", "192.168.0.0/16,fd00::/8")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if !s.BindIP.Equal(net.ParseIP("10.1.2.3")) {
+		t.Errorf("expected %v, got %v", "10.1.2.3", s.BindIP)
+	}
+	if s.AllowedNet.String() != "10.0.0.0/8" {
+		t.Errorf("expected %v, got %v", "10.0.0.0/8", s.AllowedNet.String())
+	}
+	if len(s.Trusted) != 2 || s.Trusted[1].String() != "fd00::/8" {
+		t.Errorf("expected [192.168.0.0/16 fd00::/8], got %v", s.Trusted)
+	}
+	if s.Optional != nil {
+		t.Errorf("expected <nil>, got %v", s.Optional)
+	}
+
+	os.Setenv("ENV_CONFIG_OPTIONAL", "10.0.0.0/33")
+	err := Process("env_config", &s)
+	if v, ok := err.(*ParseError); !ok || v.FieldName != "Optional" {
+		t.Errorf("expected ParseError for Optional, got %v", err)
+	}
+	os.Unsetenv("ENV_CONFIG_OPTIONAL")
+
+	os.Setenv("ENV_CONFIG_BINDIP", "10.1.2")
+	err = Process("env_config", &s)
+	if v, ok := err.(*ParseError); !ok || v.FieldName != "BindIP" {
+		t.Errorf("expected ParseError for BindIP, got %v", err)
+	}
+}
+
+func TestAliases(t *testing.T) {
+	var s struct {
+		Timeout int    `envconfig:"REQUEST_TIMEOUT" aliases:"TIMEOUT_SECONDS, TIMEOUT"`
+		Token   string `required:"true" aliases:"API_TOKEN"`
+	}
+	os.Clearenv()
+	os.Setenv("TIMEOUT", "1")
+	os.Setenv("ENV_CONFIG_TIMEOUT_SECONDS", "2")
+	os.Setenv("API_TOKEN", "secret")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Timeout != 2 {
+		t.Errorf("expected %d, got %d", 2, s.Timeout)
+	}
+	if s.Token != "secret" {
+		t.Errorf("expected %q, got %q", "secret", s.Token)
+	}
+
+	os.Setenv("REQUEST_TIMEOUT", "3")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Timeout != 3 {
+		t.Errorf("expected %d, got %d", 3, s.Timeout)
+	}
+
+	os.Setenv("ENV_CONFIG_TIMEOUT_SECONDS", "soon")
+	os.Unsetenv("REQUEST_TIMEOUT")
+	err := Process("env_config", &s)
+	if v, ok := err.(*ParseError); !ok || v.KeyName != "ENV_CONFIG_TIMEOUT_SECONDS" {
+		t.Errorf("expected ParseError for ENV_CONFIG_TIMEOUT_SECONDS, got %v", err)
+	}
+
+	os.Clearenv()
+	err = Process("env_config", &s)
+	if want := "required key ENV_CONFIG_TOKEN (or ENV_CONFIG_API_TOKEN, API_TOKEN) missing value"; err == nil || err.Error() != want {
+		t.Errorf("expected %q, got %v", want, err)
+	}
+
+	os.Setenv("ENV_CONFIG_API_TOKEN", "secret")
+	if err := CheckDisallowed("env_config", &s); err != nil {
+		t.Errorf("expected aliases to be allowed, got %v", err)
+	}
+}
+
+func TestSources(t *testing.T) {
+	var s struct {
+		Port     int
+		Host     string `default:"localhost"`
+		LogLevel string `default:"info" default_on_empty:"true"`
+		Password string
+		Debug    bool
+	}
+	path := writeDotEnv(t, "hunter2")
+	defer os.Remove(path)
+
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_PORT", "8080")
+	os.Setenv("ENV_CONFIG_LOGLEVEL", "")
+	os.Setenv("ENV_CONFIG_PASSWORD_FILE", path)
+	sources, err := Sources("env_config", &s)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	expected := map[string]Source{
+		"ENV_CONFIG_PORT":     SourceEnv,
+		"ENV_CONFIG_HOST":     SourceDefault,
+		"ENV_CONFIG_LOGLEVEL": SourceDefault,
+		"ENV_CONFIG_PASSWORD": SourceEnv,
+		"ENV_CONFIG_DEBUG":    SourceUnset,
+	}
+	if len(sources) != len(expected) {
+		t.Errorf("expected %v, got %v", expected, sources)
+	}
+	for k, v := range expected {
+		if sources[k] != v {
+			t.Errorf("%s: expected %s, got %s", k, v, sources[k])
+		}
+	}
+	if s.Port != 0 {
+		t.Errorf("expected spec to be untouched, got %d", s.Port)
+	}
+}
+
+func TestCharFields(t *testing.T) {
+	var s struct {
+		Delim   rune   `char:"true"`
+		Quote   byte   `char:"true"`
+		Marks   []rune `char:"true" separator:" "`
+		Count   int32
+		Percent uint8
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_DELIM", ";")
+	os.Setenv("ENV_CONFIG_QUOTE", "'")
+	os.Setenv("ENV_CONFIG_MARKS", "é ✓")
+	os.Setenv("ENV_CONFIG_COUNT", "42")
+	os.Setenv("ENV_CONFIG_PERCENT", "7")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Delim != ';' || s.Quote != '\'' {
+		t.Errorf("expected ';' and \"'\", got %q and %q", s.Delim, s.Quote)
+	}
+	if len(s.Marks) != 2 || s.Marks[0] != 'é' || s.Marks[1] != '✓' {
+		t.Errorf("expected [é ✓], got %q", s.Marks)
+	}
+	if s.Count != 42 || s.Percent != 7 {
+		t.Errorf("expected numbers by default, got %d and %d", s.Count, s.Percent)
 	}
-}
 
-func TestErrInvalidSpecification(t *testing.T) {
-	m := make(map[string]string)
-	err := Process("env_config", &m)
-	if err != ErrInvalidSpecification {
-		t.Errorf("expected %v, got %v", ErrInvalidSpecification, err)
+	for key, value := range map[string]string{"ENV_CONFIG_DELIM": ";;", "ENV_CONFIG_QUOTE": "é"} {
+		os.Clearenv()
+		os.Setenv(key, value)
+		if _, ok := Process("env_config", &s).(*ParseError); !ok {
+			t.Errorf("%s: expected ParseError for %q", key, value)
+		}
 	}
 }
 
-func TestUnsetVars(t *testing.T) {
-	var s Specification
+func TestSetFields(t *testing.T) {
+	var s struct {
+		Origins map[string]struct{}
+		Ports   map[int]struct{} `separator:";"`
+	}
 	os.Clearenv()
-	os.Setenv("USER", "foo")
-	os.Setenv("ENV_CONFIG_REQUIREDVAR", "foo")
+	os.Setenv("ENV_CONFIG_ORIGINS", "a.example.com,b.example.com,a.example.com")
+	os.Setenv("ENV_CONFIG_PORTS", "80;443;80")
 	if err := Process("env_config", &s); err != nil {
-		t.Error(err.Error())
+		t.Fatal(err.Error())
+	}
+	if len(s.Origins) != 2 {
+		t.Errorf("expected 2 origins, got %v", s.Origins)
+	}
+	if _, ok := s.Origins["b.example.com"]; !ok {
+		t.Errorf("expected b.example.com in %v", s.Origins)
+	}
+	if _, ok := s.Ports[443]; !ok || len(s.Ports) != 2 {
+		t.Errorf("expected map[80:{} 443:{}], got %v", s.Ports)
 	}
 
-	// If the var is not defined the non-prefixed version should not be used
-	// unless the struct tag says so
-	if s.User != "" {
-		t.Errorf("expected %q, got %q", "", s.User)
+	os.Setenv("ENV_CONFIG_PORTS", "80;http")
+	if _, ok := Process("env_config", &s).(*ParseError); !ok {
+		t.Error("expected ParseError for an invalid set item")
 	}
 }
 
-func TestAlternateVarNames(t *testing.T) {
-	var s Specification
+func TestNoAltFallback(t *testing.T) {
+	var s struct {
+		Port int    `envconfig:"PORT,noalt"`
+		Host string `envconfig:"HOST"`
+	}
 	os.Clearenv()
-	os.Setenv("ENV_CONFIG_MULTI_WORD_VAR", "foo")
-	os.Setenv("ENV_CONFIG_MULTI_WORD_VAR_WITH_ALT", "bar")
-	os.Setenv("ENV_CONFIG_MULTI_WORD_VAR_WITH_LOWER_CASE_ALT", "baz")
-	os.Setenv("ENV_CONFIG_REQUIREDVAR", "foo")
+	os.Setenv("PORT", "80")
+	os.Setenv("HOST", "global")
 	if err := Process("env_config", &s); err != nil {
-		t.Error(err.Error())
+		t.Fatal(err.Error())
 	}
-
-	// Setting the alt version of the var in the environment has no effect if
-	// the struct tag is not supplied
-	if s.MultiWordVar != "" {
-		t.Errorf("expected %q, got %q", "", s.MultiWordVar)
+	if s.Port != 0 {
+		t.Errorf("expected the bare PORT to be ignored, got %d", s.Port)
 	}
-
-	// Setting the alt version of the var in the environment correctly sets
-	// the value if the struct tag IS supplied
-	if s.MultiWordVarWithAlt != "bar" {
-		t.Errorf("expected %q, got %q", "bar", s.MultiWordVarWithAlt)
+	if s.Host != "global" {
+		t.Errorf("expected %q, got %q", "global", s.Host)
 	}
 
-	// Alt value is not case sensitive and is treated as all uppercase
-	if s.MultiWordVarWithLowerCaseAlt != "baz" {
-		t.Errorf("expected %q, got %q", "baz", s.MultiWordVarWithLowerCaseAlt)
+	os.Setenv("ENV_CONFIG_PORT", "8080")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Port != 8080 {
+		t.Errorf("expected %d, got %d", 8080, s.Port)
 	}
 }
 
@@ -383,6 +2527,99 @@ func TestRequiredMissing(t *testing.T) {
 	}
 }
 
+func TestRequiredError(t *testing.T) {
+	var s struct {
+		Token string `envconfig:"TOKEN" required:"true"`
+		Host  string `required:"true" aliases:"ADDR" desc:"the host to bind"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_TOKEN", "x")
+	err := Process("env_config", &s)
+	var re *RequiredError
+	if !errors.As(err, &re) {
+		t.Fatalf("expected RequiredError, got %#v", err)
+	}
+	if re.KeyName != "ENV_CONFIG_HOST" || re.FieldName != "Host" {
+		t.Errorf("expected ENV_CONFIG_HOST and Host, got %s and %s", re.KeyName, re.FieldName)
+	}
+	if want := "required key ENV_CONFIG_HOST (or ENV_CONFIG_ADDR, ADDR) missing value (the host to bind)"; err.Error() != want {
+		t.Errorf("expected %q, got %q", want, err.Error())
+	}
+	if !errors.Is(err, ErrRequired) {
+		t.Error("expected the error to match ErrRequired")
+	}
+
+	os.Clearenv()
+	err = ProcessAll("env_config", &s)
+	if !errors.Is(err, ErrRequired) || !errors.As(err, &re) || re.KeyName != "TOKEN" {
+		t.Errorf("expected RequiredError for TOKEN among Errors, got %v", err)
+	}
+}
+
+func TestRequiredIf(t *testing.T) {
+	var s struct {
+		TLSEnabled bool
+		CertFile   string `required_if:"TLSEnabled=true"`
+		Port       int
+		AdminToken string `required_if:"Port=8443"`
+	}
+	os.Clearenv()
+	if err := Process("env_config", &s); err != nil {
+		t.Fatalf("expected no error while TLS is off, got %v", err)
+	}
+
+	os.Setenv("ENV_CONFIG_TLSENABLED", "true")
+	err := Process("env_config", &s)
+	var re *RequiredError
+	if !errors.As(err, &re) || re.KeyName != "ENV_CONFIG_CERTFILE" {
+		t.Fatalf("expected RequiredError for ENV_CONFIG_CERTFILE, got %v", err)
+	}
+
+	os.Setenv("ENV_CONFIG_CERTFILE", "/etc/cert.pem")
+	os.Setenv("ENV_CONFIG_PORT", "8443")
+	if err := Process("env_config", &s); !errors.Is(err, ErrRequired) {
+		t.Errorf("expected ErrRequired for ENV_CONFIG_ADMINTOKEN, got %v", err)
+	}
+	os.Setenv("ENV_CONFIG_ADMINTOKEN", "x")
+	if err := Process("env_config", &s); err != nil {
+		t.Error(err.Error())
+	}
+
+	var unknown struct {
+		CertFile string `required_if:"Missing=true"`
+	}
+	if err := Process("env_config", &unknown); err == nil || !strings.Contains(err.Error(), "unknown field Missing") {
+		t.Errorf("expected an unknown field error, got %v", err)
+	}
+}
+
+func TestRequiredNonEmpty(t *testing.T) {
+	var s struct {
+		APIKey  string `required:"nonempty"`
+		Region  string `required:"nonempty" default:"eu"`
+		Present string `required:"true"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_APIKEY", "")
+	os.Setenv("ENV_CONFIG_REGION", "")
+	os.Setenv("ENV_CONFIG_PRESENT", "")
+	err := Process("env_config", &s)
+	if want := "required key ENV_CONFIG_APIKEY missing value"; err == nil || err.Error() != want {
+		t.Errorf("expected %q, got %v", want, err)
+	}
+
+	os.Setenv("ENV_CONFIG_APIKEY", "secret")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.APIKey != "secret" {
+		t.Errorf("expected %q, got %q", "secret", s.APIKey)
+	}
+	if s.Region != "eu" {
+		t.Errorf("expected %q, got %q", "eu", s.Region)
+	}
+}
+
 func TestBlankDefaultVar(t *testing.T) {
 	var s Specification
 	os.Clearenv()
@@ -453,6 +2690,80 @@ func TestAlternateNameDefaultVar(t *testing.T) {
 	}
 }
 
+func TestExpandedDefault(t *testing.T) {
+	var s struct {
+		Cache string `default:"${HOME}/cache"`
+		Addr  string `default:"$ENV_CONFIG_HOST:8080"`
+		Unset string `default:"${UNDEFINED}/x"`
+	}
+	os.Clearenv()
+	os.Setenv("HOME", "/home/kelsey")
+	os.Setenv("ENV_CONFIG_HOST", "localhost")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Cache != "/home/kelsey/cache" {
+		t.Errorf("expected %q, got %q", "/home/kelsey/cache", s.Cache)
+	}
+	if s.Addr != "localhost:8080" {
+		t.Errorf("expected %q, got %q", "localhost:8080", s.Addr)
+	}
+	if s.Unset != "/x" {
+		t.Errorf("expected %q, got %q", "/x", s.Unset)
+	}
+
+	env := map[string]string{"HOME": "/from/lookup"}
+	err := ProcessWith("env_config", &s, func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Cache != "/from/lookup/cache" {
+		t.Errorf("expected default to expand through the lookup, got %q", s.Cache)
+	}
+}
+
+func TestExpandStrict(t *testing.T) {
+	var s struct {
+		Cache   string `default:"${HOME}/cache" expand_strict:"true"`
+		DataDir string `expand:"true" expand_strict:"true"`
+	}
+	os.Clearenv()
+	os.Setenv("HOME", "/home/gopher")
+	os.Setenv("ENV_CONFIG_DATADIR", "$HOME/data")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Cache != "/home/gopher/cache" || s.DataDir != "/home/gopher/data" {
+		t.Errorf("expected defined references to expand, got %q and %q", s.Cache, s.DataDir)
+	}
+
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_DATADIR", "/data")
+	err := Process("env_config", &s)
+	if want := "envconfig: default of ENV_CONFIG_CACHE refers to undefined HOME"; err == nil || err.Error() != want {
+		t.Errorf("expected %q, got %v", want, err)
+	}
+
+	os.Setenv("HOME", "/home/gopher")
+	os.Setenv("ENV_CONFIG_DATADIR", "${DATA_ROOT}/data")
+	err = Process("env_config", &s)
+	if want := "envconfig: value of ENV_CONFIG_DATADIR refers to undefined DATA_ROOT"; err == nil || err.Error() != want {
+		t.Errorf("expected %q, got %v", want, err)
+	}
+
+	var secret struct {
+		Pass string `expand:"true" expand_strict:"true" secret:"true"`
+	}
+	os.Setenv("ENV_CONFIG_PASS", "abc$hunter2")
+	err = Process("env_config", &secret)
+	if want := "envconfig: value of ENV_CONFIG_PASS refers to undefined variables"; err == nil || err.Error() != want {
+		t.Errorf("expected %q, got %v", want, err)
+	}
+}
+
 func TestRequiredDefault(t *testing.T) {
 	var s Specification
 	os.Clearenv()
@@ -479,6 +2790,75 @@ func TestPointerFieldBlank(t *testing.T) {
 	}
 }
 
+func TestPointerScalarFields(t *testing.T) {
+	var s struct {
+		Count       *int
+		Enabled     *bool
+		Name        *string
+		WithDefault *int `default:"3"`
+		Unset       *int
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_COUNT", "0")
+	os.Setenv("ENV_CONFIG_ENABLED", "false")
+	os.Setenv("ENV_CONFIG_NAME", "")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if s.Count == nil || *s.Count != 0 {
+		t.Errorf("expected pointer to 0, got %v", s.Count)
+	}
+	if s.Enabled == nil || *s.Enabled {
+		t.Errorf("expected pointer to false, got %v", s.Enabled)
+	}
+	if s.Name == nil || *s.Name != "" {
+		t.Errorf("expected pointer to empty string, got %v", s.Name)
+	}
+	if s.WithDefault == nil || *s.WithDefault != 3 {
+		t.Errorf("expected pointer to 3, got %v", s.WithDefault)
+	}
+	if s.Unset != nil {
+		t.Errorf("expected <nil>, got %v", *s.Unset)
+	}
+}
+
+func TestBoolFlagSet(t *testing.T) {
+	var s struct {
+		Beta   *bool
+		Legacy bool
+	}
+	os.Clearenv()
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Beta != nil {
+		t.Errorf("expected <nil> for an unset flag, got %v", *s.Beta)
+	}
+	sources, err := Sources("env_config", &s)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if sources["ENV_CONFIG_LEGACY"] != SourceUnset {
+		t.Errorf("expected %s, got %s", SourceUnset, sources["ENV_CONFIG_LEGACY"])
+	}
+
+	os.Setenv("ENV_CONFIG_BETA", "true")
+	os.Setenv("ENV_CONFIG_LEGACY", "false")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Beta == nil || !*s.Beta {
+		t.Errorf("expected pointer to true, got %v", s.Beta)
+	}
+	if sources, err = Sources("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if sources["ENV_CONFIG_LEGACY"] != SourceEnv {
+		t.Errorf("expected %s for an explicit false, got %s", SourceEnv, sources["ENV_CONFIG_LEGACY"])
+	}
+}
+
 func TestEmptyMapFieldOverride(t *testing.T) {
 	var s Specification
 	os.Clearenv()
@@ -497,6 +2877,112 @@ func TestEmptyMapFieldOverride(t *testing.T) {
 	}
 }
 
+func TestSliceSeparator(t *testing.T) {
+	var s struct {
+		Paths  []string       `separator:";"`
+		Empty  []string       `separator:";"`
+		Labels map[string]int `separator:"|"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_PATHS", `C:\a,b;D:\c`)
+	os.Setenv("ENV_CONFIG_EMPTY", "")
+	os.Setenv("ENV_CONFIG_LABELS", "a:1|b:2")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(s.Paths) != 2 || s.Paths[0] != `C:\a,b` || s.Paths[1] != `D:\c` {
+		t.Errorf("expected %#v, got %#v", []string{`C:\a,b`, `D:\c`}, s.Paths)
+	}
+	if s.Empty == nil || len(s.Empty) != 0 {
+		t.Errorf("expected empty slice, got %#v", s.Empty)
+	}
+	if len(s.Labels) != 2 || s.Labels["a"] != 1 || s.Labels["b"] != 2 {
+		t.Errorf("expected %#v, got %#v", map[string]int{"a": 1, "b": 2}, s.Labels)
+	}
+}
+
+func TestMapSeparators(t *testing.T) {
+	var s struct {
+		Labels map[string]string `separator:";" kv_separator:"="`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_LABELS", "team=core;tier=1:a")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(s.Labels) != 2 || s.Labels["team"] != "core" || s.Labels["tier"] != "1:a" {
+		t.Errorf("expected %#v, got %#v", map[string]string{"team": "core", "tier": "1:a"}, s.Labels)
+	}
+
+	var urls struct {
+		Backends map[string]string
+	}
+	os.Setenv("ENV_CONFIG_BACKENDS", "a:http://x:8080,b:")
+	if err := Process("env_config", &urls); err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(urls.Backends) != 2 || urls.Backends["a"] != "http://x:8080" || urls.Backends["b"] != "" {
+		t.Errorf("expected the value to keep its colons, got %#v", urls.Backends)
+	}
+}
+
+func TestParseErrorMapPair(t *testing.T) {
+	var s Specification
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_REQUIREDVAR", "foo")
+	os.Setenv("ENV_CONFIG_COLORCODES", "red:1,green")
+	err := Process("env_config", &s)
+	v, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("expected ParseError, got %v", err)
+	}
+	if v.FieldName != "ColorCodes" {
+		t.Errorf("expected %s, got %v", "ColorCodes", v.FieldName)
+	}
+	if !strings.Contains(v.Err.Error(), `"green"`) {
+		t.Errorf("expected error to name the pair, got %q", v.Err)
+	}
+}
+
+func TestArrayFields(t *testing.T) {
+	var s struct {
+		RGB    [3]int
+		Bounds [2]float64 `separator:";"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_RGB", "255,128,0")
+	os.Setenv("ENV_CONFIG_BOUNDS", "-1.5;1.5")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.RGB != [3]int{255, 128, 0} {
+		t.Errorf("expected %v, got %v", [3]int{255, 128, 0}, s.RGB)
+	}
+	if s.Bounds != [2]float64{-1.5, 1.5} {
+		t.Errorf("expected %v, got %v", [2]float64{-1.5, 1.5}, s.Bounds)
+	}
+
+	for value, msg := range map[string]string{
+		"255,128":  "expected 3 elements, got 2",
+		"1,2,3,4":  "expected 3 elements, got 4",
+		"":         "expected 3 elements, got 0",
+		"1,blue,3": "element 1: ",
+	} {
+		os.Setenv("ENV_CONFIG_RGB", value)
+		err := Process("env_config", &s)
+		v, ok := err.(*ParseError)
+		if !ok {
+			t.Errorf("%q: expected ParseError, got %v", value, err)
+			continue
+		}
+		if !strings.HasPrefix(v.Err.Error(), msg) {
+			t.Errorf("%q: expected %q, got %q", value, msg, v.Err)
+		}
+	}
+}
+
 func TestMustProcess(t *testing.T) {
 	var s Specification
 	os.Clearenv()
@@ -519,6 +3005,51 @@ func TestMustProcess(t *testing.T) {
 	MustProcess("env_config", &m)
 }
 
+func TestMustProcessWith(t *testing.T) {
+	var s Specification
+	env := map[string]string{"ENV_CONFIG_PORT": "8080", "ENV_CONFIG_REQUIREDVAR": "foo"}
+	MustProcessWith("env_config", &s, func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	})
+	if s.Port != 8080 {
+		t.Errorf("expected %d, got %d", 8080, s.Port)
+	}
+
+	defer func() {
+		if err, ok := recover().(error); ok && err.Error() == "required key ENV_CONFIG_REQUIREDVAR missing value" {
+			return
+		}
+
+		t.Error("expected panic with the error of ProcessWith")
+	}()
+	MustProcessWith("env_config", &s, func(key string) (string, bool) {
+		return "", false
+	})
+}
+
+func TestMustProcessContext(t *testing.T) {
+	var s Specification
+	lookup := func(ctx context.Context, key string) (string, bool, error) {
+		return "foo", key == "ENV_CONFIG_REQUIREDVAR", nil
+	}
+	MustProcessContext(context.Background(), "env_config", &s, lookup)
+	if s.RequiredVar != "foo" {
+		t.Errorf("expected %q, got %q", "foo", s.RequiredVar)
+	}
+
+	defer func() {
+		if err := recover(); err == context.Canceled {
+			return
+		}
+
+		t.Error("expected panic with context.Canceled")
+	}()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	MustProcessContext(ctx, "env_config", &s, lookup)
+}
+
 func TestEmbeddedStruct(t *testing.T) {
 	var s Specification
 	os.Clearenv()
@@ -546,20 +3077,84 @@ func TestEmbeddedStruct(t *testing.T) {
 	if s.Embedded.MultiWordVar != "foo" {
 		t.Errorf("expected %s, got %s", "foo", s.Embedded.MultiWordVar)
 	}
-	if s.MultiWordVarWithAlt != "bar" {
-		t.Errorf("expected %s, got %s", "bar", s.MultiWordVarWithAlt)
+	if s.MultiWordVarWithAlt != "bar" {
+		t.Errorf("expected %s, got %s", "bar", s.MultiWordVarWithAlt)
+	}
+	if s.Embedded.MultiWordVarWithAlt != "baz" {
+		t.Errorf("expected %s, got %s", "baz", s.Embedded.MultiWordVarWithAlt)
+	}
+	if s.EmbeddedAlt != "foobar" {
+		t.Errorf("expected %s, got %s", "foobar", s.EmbeddedAlt)
+	}
+	if *s.SomePointer != "foobaz" {
+		t.Errorf("expected %s, got %s", "foobaz", *s.SomePointer)
+	}
+	if s.EmbeddedIgnored != "" {
+		t.Errorf("expected empty string, got %#v", s.Ignored)
+	}
+}
+
+type Logging struct {
+	Level string `default:"info"`
+}
+
+type Tracing struct {
+	Level    string
+	Endpoint string
+}
+
+func TestEmbeddedStructSegment(t *testing.T) {
+	var s struct {
+		Logging `envconfig:"LOG"`
+		Port    int
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_LOG_LEVEL", "debug")
+	os.Setenv("ENV_CONFIG_LEVEL", "error")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Level != "debug" {
+		t.Errorf("expected %s, got %s", "debug", s.Level)
+	}
+}
+
+func TestEmbeddedStructCollision(t *testing.T) {
+	var s struct {
+		Logging
+		Tracing
+	}
+	err := Process("env_config", &s)
+	if want := "envconfig: ENV_CONFIG_LEVEL is defined by both Logging and Tracing"; err == nil || err.Error() != want {
+		t.Errorf("expected %q, got %v", want, err)
+	}
+
+	var named struct {
+		Logging
+		Tracing `envconfig:"TRACE"`
+	}
+	if err := Process("env_config", &named); err != nil {
+		t.Error(err.Error())
 	}
-	if s.Embedded.MultiWordVarWithAlt != "baz" {
-		t.Errorf("expected %s, got %s", "baz", s.Embedded.MultiWordVarWithAlt)
+}
+
+func TestEmbeddedStructShadowed(t *testing.T) {
+	var s struct {
+		Logging
+		Level string
 	}
-	if s.EmbeddedAlt != "foobar" {
-		t.Errorf("expected %s, got %s", "foobar", s.EmbeddedAlt)
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_LEVEL", "debug")
+	report := ProcessWithReport("env_config", &s)
+	if len(report.Errors) != 0 {
+		t.Fatalf("expected no errors, got %v", report.Errors)
 	}
-	if *s.SomePointer != "foobaz" {
-		t.Errorf("expected %s, got %s", "foobaz", *s.SomePointer)
+	if s.Level != "debug" || s.Logging.Level != "debug" {
+		t.Errorf("expected both fields to read the key, got %q and %q", s.Level, s.Logging.Level)
 	}
-	if s.EmbeddedIgnored != "" {
-		t.Errorf("expected empty string, got %#v", s.Ignored)
+	want := "ENV_CONFIG_LEVEL is read by both Level and Logging.Level"
+	if len(report.Warnings) != 1 || report.Warnings[0] != want {
+		t.Errorf("expected %q, got %q", want, report.Warnings)
 	}
 }
 
@@ -630,6 +3225,34 @@ func TestCustomValueFields(t *testing.T) {
 	}
 }
 
+func TestDecoderError(t *testing.T) {
+	var s struct {
+		Level level `envconfig:"LOG_LEVEL"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_LOG_LEVEL", "loud")
+
+	err := Process("env_config", &s)
+	v, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("expected ParseError, got %v", err)
+	}
+	if v.KeyName != "ENV_CONFIG_LOG_LEVEL" {
+		t.Errorf("expected %s, got %v", "ENV_CONFIG_LOG_LEVEL", v.KeyName)
+	}
+	if v.Err != errUnknownLevel {
+		t.Errorf("expected %v, got %v", errUnknownLevel, v.Err)
+	}
+
+	os.Setenv("ENV_CONFIG_LOG_LEVEL", "debug")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Level != 1 {
+		t.Errorf("expected %d, got %d", 1, s.Level)
+	}
+}
+
 func TestCustomPointerFields(t *testing.T) {
 	var s struct {
 		Foo    string
@@ -688,6 +3311,287 @@ func TestEmptyPrefixUsesFieldNames(t *testing.T) {
 	}
 }
 
+func TestNestedStructs(t *testing.T) {
+	type server struct {
+		Host    string
+		Port    int
+		Started time.Time
+	}
+	var s struct {
+		Server server
+		DB     struct {
+			DSN string
+		} `envconfig:"DATABASE"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_SERVER_HOST", "localhost")
+	os.Setenv("ENV_CONFIG_SERVER_PORT", "8080")
+	os.Setenv("ENV_CONFIG_SERVER_STARTED", "2016-08-16T18:57:05Z")
+	os.Setenv("ENV_CONFIG_DATABASE_DSN", "postgres://")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if s.Server.Host != "localhost" {
+		t.Errorf("expected %s, got %s", "localhost", s.Server.Host)
+	}
+	if s.Server.Port != 8080 {
+		t.Errorf("expected %d, got %d", 8080, s.Server.Port)
+	}
+	if s.Server.Started.IsZero() {
+		t.Error("expected time.Time to be treated as a single value")
+	}
+	if s.DB.DSN != "postgres://" {
+		t.Errorf("expected %s, got %s", "postgres://", s.DB.DSN)
+	}
+}
+
+func TestOptionalSections(t *testing.T) {
+	type tracing struct {
+		Endpoint string  `required:"true"`
+		Rate     float64 `default:"0.1"`
+	}
+	type metrics struct {
+		Port    int `default:"9090"`
+		Tracing *tracing
+	}
+	var s struct {
+		Metrics *metrics
+		Cache   *struct {
+			Size int `required:"true"`
+		}
+		Preset *struct {
+			Name string `default:"kept"`
+		}
+	}
+	s.Preset = &struct {
+		Name string `default:"kept"`
+	}{}
+
+	os.Clearenv()
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Metrics != nil || s.Cache != nil {
+		t.Errorf("expected unconfigured sections to stay nil, got %+v and %+v", s.Metrics, s.Cache)
+	}
+	if s.Preset == nil || s.Preset.Name != "kept" {
+		t.Errorf("expected allocated sections to be processed, got %+v", s.Preset)
+	}
+
+	os.Setenv("ENV_CONFIG_METRICS_TRACING_ENDPOINT", "collector:4317")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Metrics == nil || s.Metrics.Port != 9090 {
+		t.Fatalf("expected metrics with its default port, got %+v", s.Metrics)
+	}
+	if s.Metrics.Tracing == nil || s.Metrics.Tracing.Endpoint != "collector:4317" || s.Metrics.Tracing.Rate != 0.1 {
+		t.Errorf("expected tracing to be configured, got %+v", s.Metrics.Tracing)
+	}
+
+	s.Metrics = nil
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_METRICS_PORT", "8125")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Metrics == nil || s.Metrics.Port != 8125 || s.Metrics.Tracing != nil {
+		t.Errorf("expected metrics without tracing, got %+v", s.Metrics)
+	}
+
+	os.Setenv("ENV_CONFIG_METRICS_TRACING_RATE", "0.5")
+	err := Process("env_config", &s)
+	if want := "required key ENV_CONFIG_METRICS_TRACING_ENDPOINT missing value"; err == nil || err.Error() != want {
+		t.Errorf("expected %q, got %v", want, err)
+	}
+}
+
+func TestSectionLists(t *testing.T) {
+	type server struct {
+		Host string `required:"true"`
+		Port int    `default:"80"`
+		Tags []string
+	}
+	var s struct {
+		Servers []server
+		Mirrors []*server
+		Regions []struct {
+			Name  string
+			Zones []struct {
+				ID string
+			}
+		}
+		Unused []server
+	}
+	s.Unused = []server{{Host: "kept"}}
+
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_SERVERS_0_HOST", "a.example.com")
+	os.Setenv("ENV_CONFIG_SERVERS_1_HOST", "b.example.com")
+	os.Setenv("ENV_CONFIG_SERVERS_1_PORT", "8080")
+	os.Setenv("ENV_CONFIG_SERVERS_1_TAGS", "x,y")
+	os.Setenv("ENV_CONFIG_SERVERS_3_HOST", "after the gap")
+	os.Setenv("ENV_CONFIG_MIRRORS_0_HOST", "mirror.example.com")
+	os.Setenv("ENV_CONFIG_REGIONS_0_ZONES_0_ID", "eu-1a")
+	os.Setenv("ENV_CONFIG_REGIONS_0_ZONES_1_ID", "eu-1b")
+	os.Setenv("ENV_CONFIG_REGIONS_1_NAME", "us")
+	os.Setenv("HOST", "bare names are not looked up")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(s.Servers) != 2 {
+		t.Fatalf("expected 2 servers, got %+v", s.Servers)
+	}
+	if s.Servers[0].Host != "a.example.com" || s.Servers[0].Port != 80 {
+		t.Errorf("expected a.example.com:80, got %+v", s.Servers[0])
+	}
+	if s.Servers[1].Host != "b.example.com" || s.Servers[1].Port != 8080 || len(s.Servers[1].Tags) != 2 {
+		t.Errorf("expected b.example.com:8080 [x y], got %+v", s.Servers[1])
+	}
+	if len(s.Mirrors) != 1 || s.Mirrors[0].Host != "mirror.example.com" {
+		t.Errorf("expected one mirror, got %+v", s.Mirrors)
+	}
+	if len(s.Regions) != 2 || len(s.Regions[0].Zones) != 2 || s.Regions[0].Zones[1].ID != "eu-1b" || s.Regions[1].Name != "us" {
+		t.Errorf("expected nested lists, got %+v", s.Regions)
+	}
+	if len(s.Unused) != 1 || s.Unused[0].Host != "kept" {
+		t.Errorf("expected a list without elements to stay untouched, got %+v", s.Unused)
+	}
+
+	sources, err := Sources("env_config", &s)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if sources["ENV_CONFIG_SERVERS_0_PORT"] != SourceDefault || sources["ENV_CONFIG_SERVERS_1_PORT"] != SourceEnv {
+		t.Errorf("expected element sources, got %v", sources)
+	}
+
+	os.Unsetenv("ENV_CONFIG_SERVERS_1_HOST")
+	err = Process("env_config", &s)
+	if want := "required key ENV_CONFIG_SERVERS_1_HOST missing value"; err == nil || err.Error() != want {
+		t.Errorf("expected %q, got %v", want, err)
+	}
+}
+
+func TestSectionListsSelfReferential(t *testing.T) {
+	type tree struct {
+		Name     string
+		Children []tree
+	}
+	var s tree
+	os.Clearenv()
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	os.Setenv("ENV_CONFIG_NAME", "root")
+	os.Setenv("ENV_CONFIG_CHILDREN_0_NAME", "a")
+	os.Setenv("ENV_CONFIG_CHILDREN_0_CHILDREN_0_NAME", "b")
+	os.Setenv("ENV_CONFIG_CHILDREN_1_NAME", "c")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Name != "root" || len(s.Children) != 2 || s.Children[1].Name != "c" {
+		t.Fatalf("expected root with children a and c, got %+v", s)
+	}
+	if a := s.Children[0]; a.Name != "a" || len(a.Children) != 1 || a.Children[0].Name != "b" || a.Children[0].Children != nil {
+		t.Errorf("expected a with child b, got %+v", a)
+	}
+
+	keys, err := Keys("env_config", &s)
+	if err != nil || len(keys) != 2 || keys[1].Key != "ENV_CONFIG_CHILDREN_<N>_NAME" {
+		t.Errorf("expected the keys of the root and of an element, got %v, %v", keys, err)
+	}
+}
+
+func TestSpecPrefix(t *testing.T) {
+	var s struct {
+		_    struct{} `envconfig:"prefix=MYAPP"`
+		Port int
+	}
+	os.Clearenv()
+	os.Setenv("MYAPP_PORT", "8080")
+	os.Setenv("ENV_CONFIG_PORT", "1")
+	if err := Process("", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Port != 8080 {
+		t.Errorf("expected %d, got %d", 8080, s.Port)
+	}
+	s.Port = 0
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Port != 8080 {
+		t.Errorf("expected the declared prefix to win over the argument, got %d", s.Port)
+	}
+	if err := CheckDisallowed("", &s); err != nil {
+		t.Errorf("expected the declared prefix to be checked, got %v", err)
+	}
+
+	var plain struct {
+		_    struct{} `envconfig:",split_words"`
+		Port int
+	}
+	if err := Process("env_config", &plain); err != nil {
+		t.Fatal(err.Error())
+	}
+	if plain.Port != 1 {
+		t.Errorf("expected the prefix argument without a declared prefix, got %d", plain.Port)
+	}
+}
+
+type privateHelper struct {
+	Value string
+}
+
+func TestUnexportedNestedFields(t *testing.T) {
+	var s struct {
+		privateHelper
+		Public struct {
+			Name   string
+			hidden struct{ Value string }
+			ptr    *privateHelper
+		}
+		private struct{ Value string }
+		priv    *privateHelper
+		list    []privateHelper
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_PUBLIC_NAME", "name")
+	os.Setenv("ENV_CONFIG_PUBLIC_HIDDEN_VALUE", "x")
+	os.Setenv("ENV_CONFIG_PUBLIC_PTR_VALUE", "x")
+	os.Setenv("ENV_CONFIG_VALUE", "x")
+	os.Setenv("ENV_CONFIG_PRIVATE_VALUE", "x")
+	os.Setenv("ENV_CONFIG_PRIV_VALUE", "x")
+	os.Setenv("ENV_CONFIG_LIST_0_VALUE", "x")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Public.Name != "name" {
+		t.Errorf("expected %q, got %q", "name", s.Public.Name)
+	}
+	if s.Value != "" || s.Public.hidden.Value != "" || s.Public.ptr != nil || s.private.Value != "" || s.priv != nil || s.list != nil {
+		t.Errorf("expected unexported fields to be skipped, got %+v", s)
+	}
+
+	values, err := Resolve("env_config", &s)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(values) != 1 || values["ENV_CONFIG_PUBLIC_NAME"] != "name" {
+		t.Errorf("expected only ENV_CONFIG_PUBLIC_NAME, got %v", values)
+	}
+	if err := Usagef("env_config", &s, ioutil.Discard, DefaultListFormat); err != nil {
+		t.Error(err.Error())
+	}
+	if _, err := Reload("env_config", &s); err != nil {
+		t.Error(err.Error())
+	}
+	Redacted(&s)
+}
+
 func TestNestedStructVarName(t *testing.T) {
 	var s Specification
 	os.Clearenv()
@@ -730,6 +3634,200 @@ func TestTextUnmarshalerError(t *testing.T) {
 	}
 }
 
+func TestTextUnmarshalerFields(t *testing.T) {
+	var s struct {
+		IP      net.IP
+		IPPtr   *net.IP
+		Invalid net.IP
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_IP", "10.0.0.1")
+	os.Setenv("ENV_CONFIG_IPPTR", "10.0.0.2")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if want := net.ParseIP("10.0.0.1"); !s.IP.Equal(want) {
+		t.Errorf("expected %v, got %v", want, s.IP)
+	}
+	if want := net.ParseIP("10.0.0.2"); s.IPPtr == nil || !s.IPPtr.Equal(want) {
+		t.Errorf("expected %v, got %v", want, s.IPPtr)
+	}
+
+	os.Setenv("ENV_CONFIG_INVALID", "not-an-ip")
+	err := Process("env_config", &s)
+	v, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("expected ParseError, got %v", err)
+	}
+	if v.FieldName != "Invalid" {
+		t.Errorf("expected %s, got %v", "Invalid", v.FieldName)
+	}
+}
+
+type (
+	port   uint16
+	ratio  float64
+	toggle bool
+	label  string
+)
+
+func TestNamedTypes(t *testing.T) {
+	var s struct {
+		Port    port
+		Ratio   ratio
+		Verbose toggle
+		Name    label
+		Ports   []port
+		Weights map[label]ratio
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_PORT", "8080")
+	os.Setenv("ENV_CONFIG_RATIO", "0.75")
+	os.Setenv("ENV_CONFIG_VERBOSE", "true")
+	os.Setenv("ENV_CONFIG_NAME", "api")
+	os.Setenv("ENV_CONFIG_PORTS", "80,443")
+	os.Setenv("ENV_CONFIG_WEIGHTS", "a:0.5")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Port != 8080 || s.Ratio != 0.75 || !s.Verbose || s.Name != "api" {
+		t.Errorf("expected 8080, 0.75, true and api, got %v, %v, %v and %v", s.Port, s.Ratio, s.Verbose, s.Name)
+	}
+	if len(s.Ports) != 2 || s.Ports[1] != 443 || s.Weights["a"] != 0.5 {
+		t.Errorf("expected [80 443] and map[a:0.5], got %v and %v", s.Ports, s.Weights)
+	}
+
+	os.Setenv("ENV_CONFIG_PORT", "70000")
+	err := Process("env_config", &s)
+	if want := "value out of range for envconfig.port (uint16)"; err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("expected %q, got %v", want, err)
+	}
+}
+
+func TestInterfaceFields(t *testing.T) {
+	var s struct {
+		Raw      interface{}
+		Count    interface{} `infer:"true"`
+		Ratio    interface{} `infer:"true"`
+		Enabled  interface{} `infer:"true"`
+		Name     interface{} `infer:"true"`
+		Unset    interface{}
+		Stringer fmt.Stringer
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_RAW", "42")
+	os.Setenv("ENV_CONFIG_COUNT", "42")
+	os.Setenv("ENV_CONFIG_RATIO", "0.5")
+	os.Setenv("ENV_CONFIG_ENABLED", "true")
+	os.Setenv("ENV_CONFIG_NAME", "api")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	for name, c := range map[string]struct{ got, want interface{} }{
+		"Raw":     {s.Raw, "42"},
+		"Count":   {s.Count, 42},
+		"Ratio":   {s.Ratio, 0.5},
+		"Enabled": {s.Enabled, true},
+		"Name":    {s.Name, "api"},
+		"Unset":   {s.Unset, nil},
+	} {
+		if c.got != c.want {
+			t.Errorf("%s: expected %#v, got %#v", name, c.want, c.got)
+		}
+	}
+
+	for value, want := range map[string]interface{}{
+		"2e3": 2000.0, "-1.5": -1.5, "inf": "inf", "NaN": "NaN",
+		"-Infinity": "-Infinity", "0x1p-2": "0x1p-2",
+	} {
+		os.Setenv("ENV_CONFIG_RATIO", value)
+		if err := Process("env_config", &s); err != nil {
+			t.Fatal(err.Error())
+		}
+		if s.Ratio != want {
+			t.Errorf("%s: expected %#v, got %#v", value, want, s.Ratio)
+		}
+	}
+
+	os.Setenv("ENV_CONFIG_STRINGER", "x")
+	err := Process("env_config", &s)
+	if v, ok := err.(*ParseError); !ok || !errors.Is(v, ErrUnsupportedFieldType) {
+		t.Errorf("expected ParseError wrapping ErrUnsupportedFieldType, got %v", err)
+	}
+}
+
+func TestTimeFormat(t *testing.T) {
+	var s struct {
+		Embargo time.Time  `format:"2006-01-02"`
+		Expiry  *time.Time `format:"02 Jan 06 15:04 MST"`
+		Default time.Time
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_EMBARGO", "2016-08-16")
+	os.Setenv("ENV_CONFIG_EXPIRY", "16 Aug 16 18:57 UTC")
+	os.Setenv("ENV_CONFIG_DEFAULT", "2016-08-16T18:57:05Z")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if expected := time.Date(2016, 8, 16, 0, 0, 0, 0, time.UTC); !s.Embargo.Equal(expected) {
+		t.Errorf("expected %s, got %s", expected, s.Embargo)
+	}
+	if expected := time.Date(2016, 8, 16, 18, 57, 0, 0, time.UTC); s.Expiry == nil || !s.Expiry.Equal(expected) {
+		t.Errorf("expected %s, got %v", expected, s.Expiry)
+	}
+	if expected := time.Date(2016, 8, 16, 18, 57, 5, 0, time.UTC); !s.Default.Equal(expected) {
+		t.Errorf("expected %s, got %s", expected, s.Default)
+	}
+
+	os.Setenv("ENV_CONFIG_EMBARGO", "2016-08-16T18:57:05Z")
+	err := Process("env_config", &s)
+	v, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("expected ParseError, got %v", err)
+	}
+	if v.FieldName != "Embargo" || v.Value != "2016-08-16T18:57:05Z" {
+		t.Errorf("expected Embargo with its value, got %s %q", v.FieldName, v.Value)
+	}
+}
+
+func TestTimeFormatCollections(t *testing.T) {
+	var s struct {
+		Blackouts []time.Time          `format:"2006-01-02"`
+		Windows   map[string]time.Time `format:"15.04"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_BLACKOUTS", "2016-12-24,2016-12-31")
+	os.Setenv("ENV_CONFIG_WINDOWS", "start:22.00,end:06.00")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	want := []time.Time{
+		time.Date(2016, 12, 24, 0, 0, 0, 0, time.UTC),
+		time.Date(2016, 12, 31, 0, 0, 0, 0, time.UTC),
+	}
+	if len(s.Blackouts) != 2 || !s.Blackouts[0].Equal(want[0]) || !s.Blackouts[1].Equal(want[1]) {
+		t.Errorf("expected %v, got %v", want, s.Blackouts)
+	}
+	if got := s.Windows["end"]; got.Hour() != 6 || got.Minute() != 0 {
+		t.Errorf("expected 06:00 for end, got %v", got)
+	}
+
+	os.Setenv("ENV_CONFIG_BLACKOUTS", "2016-12-24,24-12-2016")
+	err := Process("env_config", &s)
+	if v, ok := err.(*ParseError); !ok || !strings.HasPrefix(v.Err.Error(), "element 1: ") {
+		t.Errorf("expected ParseError for element 1, got %v", err)
+	}
+
+	os.Setenv("ENV_CONFIG_BLACKOUTS", "2016-12-24")
+	os.Setenv("ENV_CONFIG_WINDOWS", "start:22.00,end:6am")
+	err = Process("env_config", &s)
+	if v, ok := err.(*ParseError); !ok || !strings.HasPrefix(v.Err.Error(), `key "end": `) {
+		t.Errorf("expected ParseError for key end, got %v", err)
+	}
+}
+
 func TestBinaryUnmarshalerError(t *testing.T) {
 	var s Specification
 	os.Clearenv()
@@ -781,6 +3879,34 @@ func TestCheckDisallowedMispelled(t *testing.T) {
 	}
 }
 
+func TestCheckDisallowedListsAll(t *testing.T) {
+	var s Specification
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_DEBUG", "true")
+	os.Setenv("ENV_CONFIG_ZEBUG", "false")
+	os.Setenv("ENV_CONFIG_PROT", "8080")
+	os.Setenv("ENV_CONFIG_OUTER_INNER", "nested")
+	os.Setenv("ENV_CONFIG_MULTI_WORD_VAR_WITH_AUTO_SPLIT", "24")
+	err := CheckDisallowed("env_config", &s)
+	if experr := "unknown environment variables ENV_CONFIG_PROT, ENV_CONFIG_ZEBUG"; err == nil || err.Error() != experr {
+		t.Errorf("expected %s, got %v", experr, err)
+	}
+}
+
+func TestCheckDisallowedFileVars(t *testing.T) {
+	var s struct {
+		Password string
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_PASSWORD_FILE", "/run/secrets/password")
+	if err := CheckDisallowed("env_config", &s); err != nil {
+		t.Errorf("expected the file variant to be allowed, got %v", err)
+	}
+	if r := ProcessWithReport("env_config", &s); len(r.Warnings) != 0 {
+		t.Errorf("expected no warnings, got %v", r.Warnings)
+	}
+}
+
 func TestCheckDisallowedIgnored(t *testing.T) {
 	var s Specification
 	os.Clearenv()
@@ -794,7 +3920,7 @@ func TestCheckDisallowedIgnored(t *testing.T) {
 
 func TestErrorMessageForRequiredAltVar(t *testing.T) {
 	var s struct {
-		Foo    string `envconfig:"BAR" required:"true"`
+		Foo string `envconfig:"BAR" required:"true"`
 	}
 
 	os.Clearenv()
@@ -809,6 +3935,25 @@ func TestErrorMessageForRequiredAltVar(t *testing.T) {
 	}
 }
 
+func TestErrorMessageForRequiredWithDescription(t *testing.T) {
+	var s struct {
+		DSN  string `required:"true" description:"postgres connection string"`
+		Port int    `required:"true"`
+	}
+
+	os.Clearenv()
+	err := Process("env_config", &s)
+	if want := "required key ENV_CONFIG_DSN missing value (postgres connection string)"; err == nil || err.Error() != want {
+		t.Errorf("expected %q, got %v", want, err)
+	}
+
+	os.Setenv("ENV_CONFIG_DSN", "postgres://")
+	err = Process("env_config", &s)
+	if want := "required key ENV_CONFIG_PORT missing value"; err == nil || err.Error() != want {
+		t.Errorf("expected %q, got %v", want, err)
+	}
+}
+
 type bracketed string
 
 func (b *bracketed) Set(value string) error {
@@ -829,6 +3974,18 @@ func (d quoted) Decode(value string) error {
 	return d.Set(`"` + value + `"`)
 }
 
+var errUnknownLevel = errors.New("unknown level")
+
+type level int
+
+func (l *level) Decode(value string) error {
+	if value != "debug" {
+		return errUnknownLevel
+	}
+	*l = 1
+	return nil
+}
+
 type setterStruct struct {
 	Inner string
 }
@@ -1 +1,3 @@
 module github.com/mbict/envconfig
+
+go 1.13
@@ -0,0 +1,275 @@
+// Copyright (c) 2016 Kelsey Hightower and others. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package envconfig
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Processor processes a single specification type. The field metadata of the
+// type is gathered once per prefix and reused on every call, which makes a
+// Processor cheaper than Process when the same specification is processed
+// over and over again, for instance to reload configuration on SIGHUP.
+//
+// A Processor is safe for concurrent use. Its options must be set before the
+// first call to Process.
+type Processor struct {
+	// Separator joins the prefix and the name of a field, as well as the
+	// names of nested structs, into a key. It defaults to "_".
+	Separator string
+
+	// DisableAltFallback turns off the fallback from a prefixed key to the
+	// bare name given in the envconfig tag, so that a generic variable such
+	// as PORT never leaks into MYAPP_PORT. The noalt tag option does the same
+	// for a single field.
+	DisableAltFallback bool
+
+	// PreserveCase looks every key up as written in the prefix, the field
+	// name and the tags before falling back to its upper case form, so
+	// MyApp_Port is tried before MYAPP_PORT.
+	PreserveCase bool
+
+	// OnResolve, if set, is called for every variable once Process is done
+	// with it, with the key that supplied the value and the name of its
+	// field. The source is "env" when a value was assigned, "default" when
+	// the default was and "unset" when the field was skipped. Masked is true
+	// for secret fields, whose values must not be logged.
+	OnResolve func(key, fieldName, source string, masked bool)
+
+	// TagName is the struct tag that holds the name and options of a field,
+	// "envconfig" by default. Setting it to "env" reads structs annotated
+	// for another library. Separate tags such as default and required keep
+	// their names.
+	TagName string
+
+	// KeyFunc, if set, builds the key of every field from the prefix, which
+	// includes the keys of enclosing structs, the name of the field and its
+	// struct tag. The key is looked up exactly as returned, options such as
+	// exact and noprefix are up to KeyFunc. Alt names and aliases are kept.
+	KeyFunc func(prefix, fieldName string, tag reflect.StructTag) string
+
+	// SecondaryPrefix, if set, is tried in place of the prefix passed to
+	// Process for every key that has no value, before the bare names of the
+	// envconfig tags, defaults and required checks apply. It suits platforms
+	// that inject variables under their own prefix, such as PLATFORM_PORT
+	// next to MYAPP_PORT.
+	SecondaryPrefix string
+
+	// Strict makes Process fail before assigning anything if an environment
+	// variable with the prefix is set that no field reads, if a variable is
+	// set for a field of an unsupported type or if a field has an unknown
+	// envconfig tag option or a tag that looks like a misspelling, such as
+	// requird. All violations are returned together as Errors of
+	// StrictError.
+	Strict bool
+
+	typ      reflect.Type
+	decoders map[reflect.Type]func(string) (interface{}, error)
+
+	mu    sync.Mutex
+	infos map[string][]varInfo
+}
+
+// NewProcessor returns a Processor for the type of spec, which must be a
+// struct pointer.
+func NewProcessor(spec interface{}) (*Processor, error) {
+	s, err := specValue(spec)
+	if err != nil {
+		return nil, err
+	}
+	return &Processor{
+		typ:   s.Type(),
+		infos: make(map[string][]varInfo),
+	}, nil
+}
+
+// Process populates spec from the environment like Process does. The type of
+// spec must match the specification the Processor was created with.
+func (p *Processor) Process(prefix string, spec interface{}) error {
+	s, err := specValue(spec)
+	if err != nil {
+		return err
+	}
+	if p.typ == nil {
+		return errors.New("envconfig: Processor must be created with NewProcessor")
+	}
+	if s.Type() != p.typ {
+		return fmt.Errorf("envconfig: processor for %s cannot process %s", p.typ, s.Type())
+	}
+
+	cached, err := p.gather(prefix)
+	if err != nil {
+		return err
+	}
+
+	// the cached variables are shared, bind a copy to this specification
+	infos := make([]varInfo, len(cached))
+	copy(infos, cached)
+	bindInfos(s, infos)
+	lookup := plainLookup(p.lookup(prefix))
+	if infos, err = p.expandLists(infos, lookup); err != nil {
+		return err
+	}
+	if err := detectSections(infos, lookup); err != nil {
+		return err
+	}
+	if p.Strict {
+		if err := p.checkStrict(prefix, spec, infos, lookup); err != nil {
+			return err
+		}
+	}
+
+	for _, info := range infos {
+		if err := p.processInfo(info, lookup); err != nil {
+			return err
+		}
+	}
+	return p.postProcess(spec, infos, lookup)
+}
+
+// lookup returns the lookup of Process for prefix, which falls back to the
+// SecondaryPrefix if one is set.
+func (p *Processor) lookup(prefix string) func(key string) (string, bool) {
+	secondary := strings.TrimSpace(p.SecondaryPrefix)
+	if secondary == "" {
+		return envLookup
+	}
+	first := ""
+	if prefix = strings.TrimSpace(p.specPrefix(p.typ, prefix)); prefix != "" {
+		first = prefix + p.separator()
+	}
+	secondary += p.separator()
+	// keys are only tried as written with PreserveCase
+	if !p.PreserveCase {
+		first, secondary = strings.ToUpper(first), strings.ToUpper(secondary)
+	}
+	return layeredLookup(first, []string{secondary}, envLookup)
+}
+
+// RegisterDecoder registers decode as the decoder for fields of type t. It
+// takes precedence over every other way of parsing a value, which makes it
+// possible to support types from other packages such as a UUID or decimal
+// type. The value returned by decode must be assignable to t.
+//
+// Decoders are registered per Processor and must be registered before the
+// first call to Process.
+func (p *Processor) RegisterDecoder(t reflect.Type, decode func(value string) (interface{}, error)) {
+	if p.decoders == nil {
+		p.decoders = make(map[reflect.Type]func(string) (interface{}, error))
+	}
+	p.decoders[t] = decode
+}
+
+// RegisterEnum registers a decoder for the integer type t that reads the
+// names in values, such as "debug" for a log level, and assigns the number
+// they stand for. Names are matched regardless of case. Unknown names are
+// reported with the list of valid ones. RegisterEnum panics if t is not an
+// integer type.
+func (p *Processor) RegisterEnum(t reflect.Type, values map[string]int64) {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+	default:
+		panic("envconfig: RegisterEnum of non-integer type " + t.String())
+	}
+
+	// valid names are listed in the order of their values
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if values[names[i]] != values[names[j]] {
+			return values[names[i]] < values[names[j]]
+		}
+		return names[i] < names[j]
+	})
+
+	p.RegisterDecoder(t, func(value string) (interface{}, error) {
+		n, ok := values[value]
+		if !ok {
+			for _, name := range names {
+				if strings.EqualFold(name, value) {
+					n, ok = values[name], true
+					break
+				}
+			}
+		}
+		if !ok {
+			return nil, fmt.Errorf("unknown name %q, valid names are %s", value, strings.Join(names, ", "))
+		}
+		v := reflect.New(t).Elem()
+		if t.Kind() >= reflect.Uint && t.Kind() <= reflect.Uint64 {
+			v.SetUint(uint64(n))
+		} else {
+			v.SetInt(n)
+		}
+		return v.Interface(), nil
+	})
+}
+
+// hasDecoder reports whether a decoder is registered for any of types.
+func (p *Processor) hasDecoder(types ...reflect.Type) bool {
+	for _, t := range types {
+		if _, ok := p.decoders[t]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// decode assigns value to field with the decoder registered for its type. It
+// reports false if there is none.
+func (p *Processor) decode(value string, field reflect.Value) (bool, error) {
+	decode, ok := p.decoders[field.Type()]
+	if !ok {
+		return false, nil
+	}
+	v, err := decode(value)
+	if err != nil {
+		return true, err
+	}
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() || !rv.Type().AssignableTo(field.Type()) {
+		return true, fmt.Errorf("decoder returned %T, which is not assignable to %s", v, field.Type())
+	}
+	field.Set(rv)
+	return true, nil
+}
+
+// gather returns the unbound variables of the specification for prefix.
+func (p *Processor) gather(prefix string) ([]varInfo, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if infos, ok := p.infos[prefix]; ok {
+		return infos, nil
+	}
+	infos, err := p.gatherType(p.specPrefix(p.typ, prefix), p.typ, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	p.infos[prefix] = infos
+	return infos, nil
+}
+
+func (p *Processor) tagName() string {
+	if p.TagName == "" {
+		return "envconfig"
+	}
+	return p.TagName
+}
+
+func (p *Processor) separator() string {
+	if p.Separator == "" {
+		return "_"
+	}
+	return p.Separator
+}
@@ -0,0 +1,470 @@
+// Copyright (c) 2016 Kelsey Hightower and others. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package envconfig
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestProcessor(t *testing.T) {
+	var s Specification
+	p, err := NewProcessor(&s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_PORT", "8080")
+	os.Setenv("ENV_CONFIG_REQUIREDVAR", "foo")
+	os.Setenv("ENV_CONFIG_OUTER_INNER", "iamnested")
+	os.Setenv("ENV_CONFIG_URLPOINTER", "https://github.com/kelseyhightower/envconfig")
+	if err := p.Process("env_config", &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Port != 8080 {
+		t.Errorf("expected %d, got %d", 8080, s.Port)
+	}
+	if s.NestedSpecification.Property != "iamnested" {
+		t.Errorf("expected %q, got %q", "iamnested", s.NestedSpecification.Property)
+	}
+	if s.UrlPointer == nil || s.UrlPointer.Value.Host != "github.com" {
+		t.Errorf("expected url pointer to be set, got %#v", s.UrlPointer)
+	}
+
+	// a second run reuses the cached fields but binds them to the new value
+	var s2 Specification
+	os.Setenv("ENV_CONFIG_PORT", "9090")
+	if err := p.Process("env_config", &s2); err != nil {
+		t.Fatal(err)
+	}
+	if s2.Port != 9090 {
+		t.Errorf("expected %d, got %d", 9090, s2.Port)
+	}
+	if s.Port != 8080 {
+		t.Errorf("expected first spec to be untouched, got %d", s.Port)
+	}
+
+	os.Clearenv()
+	if err := p.Process("env_config", &s2); err == nil {
+		t.Error("expected required error")
+	}
+}
+
+func TestProcessorInvalidSpecification(t *testing.T) {
+	if _, err := NewProcessor(Specification{}); err != ErrInvalidSpecification {
+		t.Errorf("expected %v, got %v", ErrInvalidSpecification, err)
+	}
+
+	p, err := NewProcessor(&Specification{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Process("env_config", &Embedded{}); err == nil {
+		t.Error("expected an error for a different specification type")
+	}
+
+	err = (&Processor{}).Process("env_config", &Specification{})
+	if want := "envconfig: Processor must be created with NewProcessor"; err == nil || err.Error() != want {
+		t.Errorf("expected %q, got %v", want, err)
+	}
+}
+
+func TestProcessorSeparator(t *testing.T) {
+	var s struct {
+		Port   int
+		Server struct {
+			Host string
+		}
+		Broker string `envconfig:"BROKER"`
+		Embedded
+	}
+	p, err := NewProcessor(&s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Separator = "__"
+
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG__PORT", "8080")
+	os.Setenv("ENV_CONFIG_PORT", "9090")
+	os.Setenv("ENV_CONFIG__SERVER__HOST", "localhost")
+	os.Setenv("BROKER", "127.0.0.1")
+	os.Setenv("ENV_CONFIG__EMBEDDEDPORT", "7070")
+	if err := p.Process("env_config", &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Port != 8080 {
+		t.Errorf("expected %d, got %d", 8080, s.Port)
+	}
+	if s.Server.Host != "localhost" {
+		t.Errorf("expected %q, got %q", "localhost", s.Server.Host)
+	}
+	if s.Broker != "127.0.0.1" {
+		t.Errorf("expected %q, got %q", "127.0.0.1", s.Broker)
+	}
+	if s.EmbeddedPort != 7070 {
+		t.Errorf("expected %d, got %d", 7070, s.EmbeddedPort)
+	}
+}
+
+type point struct {
+	X, Y int
+}
+
+func TestProcessorRegisterDecoder(t *testing.T) {
+	var s struct {
+		Origin  point
+		Target  *point
+		Path    []point `separator:";"`
+		Invalid net.IP
+	}
+	p, err := NewProcessor(&s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.RegisterDecoder(reflect.TypeOf(point{}), func(value string) (interface{}, error) {
+		var pt point
+		_, err := fmt.Sscanf(value, "%d,%d", &pt.X, &pt.Y)
+		return pt, err
+	})
+	p.RegisterDecoder(reflect.TypeOf(net.IP{}), func(value string) (interface{}, error) {
+		return value, nil
+	})
+
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_ORIGIN", "1,2")
+	os.Setenv("ENV_CONFIG_ORIGIN_X", "10")
+	os.Setenv("ENV_CONFIG_TARGET", "3,4")
+	os.Setenv("ENV_CONFIG_PATH", "5,6;7,8")
+	if err := p.Process("env_config", &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Origin != (point{1, 2}) {
+		t.Errorf("expected %v, got %v", point{1, 2}, s.Origin)
+	}
+	if s.Target == nil || *s.Target != (point{3, 4}) {
+		t.Errorf("expected %v, got %v", point{3, 4}, s.Target)
+	}
+	if len(s.Path) != 2 || s.Path[1] != (point{7, 8}) {
+		t.Errorf("expected %v, got %v", []point{{5, 6}, {7, 8}}, s.Path)
+	}
+
+	os.Setenv("ENV_CONFIG_ORIGIN", "1")
+	if _, ok := p.Process("env_config", &s).(*ParseError); !ok {
+		t.Errorf("expected a ParseError for a failing decoder")
+	}
+
+	os.Setenv("ENV_CONFIG_ORIGIN", "1,2")
+	os.Setenv("ENV_CONFIG_INVALID", "127.0.0.1")
+	err = p.Process("env_config", &s)
+	want := "decoder returned string, which is not assignable to net.IP"
+	if v, ok := err.(*ParseError); !ok || v.Err.Error() != want {
+		t.Errorf("expected %q, got %v", want, err)
+	}
+}
+
+type logLevel int8
+
+func (l logLevel) String() string { return strconv.Itoa(int(l)) }
+
+func TestProcessorRegisterEnum(t *testing.T) {
+	var s struct {
+		Level  logLevel
+		Levels []logLevel
+		Mode   *uint8
+	}
+	p, err := NewProcessor(&s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.RegisterEnum(reflect.TypeOf(logLevel(0)), map[string]int64{"debug": -1, "info": 0, "warn": 1, "error": 2})
+	p.RegisterEnum(reflect.TypeOf(uint8(0)), map[string]int64{"read": 4, "write": 2})
+
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_LEVEL", "debug")
+	os.Setenv("ENV_CONFIG_LEVELS", "WARN,error")
+	os.Setenv("ENV_CONFIG_MODE", "write")
+	if err := p.Process("env_config", &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Level != -1 {
+		t.Errorf("expected %d, got %d", -1, s.Level)
+	}
+	if len(s.Levels) != 2 || s.Levels[0] != 1 || s.Levels[1] != 2 {
+		t.Errorf("expected [1 2], got %v", s.Levels)
+	}
+	if s.Mode == nil || *s.Mode != 2 {
+		t.Errorf("expected 2, got %v", s.Mode)
+	}
+
+	os.Setenv("ENV_CONFIG_LEVEL", "verbose")
+	err = p.Process("env_config", &s)
+	v, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("expected ParseError, got %v", err)
+	}
+	if want := `unknown name "verbose", valid names are debug, info, warn, error`; v.Err.Error() != want {
+		t.Errorf("expected %q, got %q", want, v.Err.Error())
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected RegisterEnum to panic for a string type")
+		}
+	}()
+	p.RegisterEnum(reflect.TypeOf(""), nil)
+}
+
+func TestProcessorDisableAltFallback(t *testing.T) {
+	var s struct {
+		Port    int `envconfig:"PORT" required:"true"`
+		Timeout int `envconfig:"REQUEST_TIMEOUT" aliases:"TIMEOUT"`
+	}
+	p, err := NewProcessor(&s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.DisableAltFallback = true
+
+	os.Clearenv()
+	os.Setenv("PORT", "80")
+	os.Setenv("TIMEOUT", "5")
+	err = p.Process("env_config", &s)
+	if want := "required key ENV_CONFIG_PORT missing value"; err == nil || err.Error() != want {
+		t.Errorf("expected %q, got %v", want, err)
+	}
+
+	os.Setenv("ENV_CONFIG_PORT", "8080")
+	os.Setenv("ENV_CONFIG_TIMEOUT", "10")
+	if err := p.Process("env_config", &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Port != 8080 || s.Timeout != 10 {
+		t.Errorf("expected 8080 and 10, got %d and %d", s.Port, s.Timeout)
+	}
+}
+
+func TestProcessorPreserveCase(t *testing.T) {
+	var s struct {
+		Port     int
+		Host     string `envconfig:"Host"`
+		Nested   struct{ LogLevel string }
+		Fallback string
+	}
+	p, err := NewProcessor(&s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.PreserveCase = true
+
+	os.Clearenv()
+	os.Setenv("MyApp_Port", "8080")
+	os.Setenv("MYAPP_PORT", "80")
+	os.Setenv("MyApp_Host", "example.com")
+	os.Setenv("MyApp_Nested_LogLevel", "debug")
+	os.Setenv("MYAPP_FALLBACK", "upper")
+	if err := p.Process("MyApp", &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Port != 8080 {
+		t.Errorf("expected the key as written to win, got %d", s.Port)
+	}
+	if s.Host != "example.com" || s.Nested.LogLevel != "debug" {
+		t.Errorf("expected example.com and debug, got %q and %q", s.Host, s.Nested.LogLevel)
+	}
+	if s.Fallback != "upper" {
+		t.Errorf("expected the upper case key as fallback, got %q", s.Fallback)
+	}
+
+	os.Unsetenv("MyApp_Host")
+	os.Setenv("Host", "bare")
+	os.Setenv("HOST", "BARE")
+	if err := p.Process("MyApp", &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Host != "bare" {
+		t.Errorf("expected the alt name as written, got %q", s.Host)
+	}
+}
+
+func TestProcessorOnResolve(t *testing.T) {
+	var s struct {
+		Port     int    `envconfig:"PORT"`
+		Host     string `default:"localhost"`
+		Password string `secret:"true"`
+		Debug    bool
+		Cache    *struct{ Size int }
+	}
+	p, err := NewProcessor(&s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []string
+	p.OnResolve = func(key, fieldName, source string, masked bool) {
+		events = append(events, fmt.Sprintf("%s %s %s %t", key, fieldName, source, masked))
+	}
+
+	os.Clearenv()
+	os.Setenv("PORT", "80")
+	os.Setenv("ENV_CONFIG_PASSWORD", "hunter2")
+	if err := p.Process("env_config", &s); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"PORT Port env false",
+		"ENV_CONFIG_HOST Host default false",
+		"ENV_CONFIG_PASSWORD Password env true",
+		"ENV_CONFIG_DEBUG Debug unset false",
+		"ENV_CONFIG_CACHE_SIZE Size unset false",
+	}
+	if strings.Join(events, "\n") != strings.Join(want, "\n") {
+		t.Errorf("expected %q, got %q", want, events)
+	}
+}
+
+func TestProcessorTagName(t *testing.T) {
+	var s struct {
+		_       struct{} `env:"prefix=MYAPP"`
+		Port    int      `env:"LISTEN_PORT,required"`
+		Host    string   `env:"-"`
+		Timeout int      `envconfig:"IGNORED_NAME" default:"30"`
+	}
+	p, err := NewProcessor(&s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.TagName = "env"
+
+	os.Clearenv()
+	os.Setenv("MYAPP_LISTEN_PORT", "8080")
+	os.Setenv("MYAPP_HOST", "example.com")
+	if err := p.Process("", &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Port != 8080 || s.Host != "" || s.Timeout != 30 {
+		t.Errorf("expected 8080, no host and 30, got %d, %q and %d", s.Port, s.Host, s.Timeout)
+	}
+
+	os.Unsetenv("MYAPP_LISTEN_PORT")
+	if err := p.Process("", &s); !errors.Is(err, ErrRequired) {
+		t.Errorf("expected options in the env tag to apply, got %v", err)
+	}
+}
+
+func TestProcessorKeyFunc(t *testing.T) {
+	var s struct {
+		ListenPort int
+		Database   struct {
+			MaxConns int `key:"pool-size"`
+		}
+	}
+	p, err := NewProcessor(&s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.KeyFunc = func(prefix, fieldName string, tag reflect.StructTag) string {
+		name := tag.Get("key")
+		if name == "" {
+			name = strings.ToLower(fieldName)
+		}
+		if prefix == "" {
+			return name
+		}
+		return prefix + "." + name
+	}
+
+	os.Clearenv()
+	os.Setenv("myapp.listenport", "8080")
+	os.Setenv("myapp.database.pool-size", "16")
+	if err := p.Process("myapp", &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.ListenPort != 8080 || s.Database.MaxConns != 16 {
+		t.Errorf("expected 8080 and 16, got %d and %d", s.ListenPort, s.Database.MaxConns)
+	}
+}
+
+func benchmarkEnv() {
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_DEBUG", "true")
+	os.Setenv("ENV_CONFIG_PORT", "8080")
+	os.Setenv("ENV_CONFIG_ADMINUSERS", "John,Adam,Will")
+	os.Setenv("ENV_CONFIG_COLORCODES", "red:1,green:2,blue:3")
+	os.Setenv("ENV_CONFIG_REQUIREDVAR", "foo")
+}
+
+func TestProcessorSecondaryPrefix(t *testing.T) {
+	type spec struct {
+		Port   int
+		Host   string `default:"localhost"`
+		Region string `envconfig:"REGION"`
+		Token  string `required:"true"`
+	}
+	var s spec
+	p, err := NewProcessor(&s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.SecondaryPrefix = "platform"
+
+	os.Clearenv()
+	os.Setenv("MYAPP_PORT", "8080")
+	os.Setenv("PLATFORM_PORT", "9090")
+	os.Setenv("PLATFORM_REGION", "eu")
+	os.Setenv("REGION", "us")
+	os.Setenv("PLATFORM_TOKEN", "x")
+	if err := p.Process("myapp", &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Port != 8080 {
+		t.Errorf("expected the primary prefix to win, got %d", s.Port)
+	}
+	if s.Region != "eu" {
+		t.Errorf("expected the secondary prefix to win over the bare name, got %s", s.Region)
+	}
+	if s.Host != "localhost" || s.Token != "x" {
+		t.Errorf("expected localhost and x, got %s and %s", s.Host, s.Token)
+	}
+
+	os.Unsetenv("PLATFORM_TOKEN")
+	err = p.Process("myapp", &s)
+	var re *RequiredError
+	if !errors.As(err, &re) || re.KeyName != "MYAPP_TOKEN" {
+		t.Errorf("expected RequiredError for MYAPP_TOKEN, got %v", err)
+	}
+}
+
+func BenchmarkProcess(b *testing.B) {
+	benchmarkEnv()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var s Specification
+		if err := Process("env_config", &s); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkProcessor(b *testing.B) {
+	benchmarkEnv()
+	p, err := NewProcessor(&Specification{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var s Specification
+		if err := p.Process("env_config", &s); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
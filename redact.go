@@ -0,0 +1,91 @@
+// Copyright (c) 2016 Kelsey Hightower and others. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package envconfig
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Redacted formats spec like fmt does for the %+v verb, with the value of
+// every field tagged secret replaced by ***, so that a configuration can be
+// logged safely. Nested structs, pointers to them and slices of them are
+// formatted field by field, nil pointers as <nil>. Other values, such as a
+// time.Time, are formatted by fmt.
+func Redacted(spec interface{}) string {
+	var b strings.Builder
+	(&Processor{}).writeRedacted(&b, reflect.ValueOf(spec))
+	return b.String()
+}
+
+// writeRedacted writes the redacted form of v to b.
+func (p *Processor) writeRedacted(b *strings.Builder, v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Invalid:
+		b.WriteString("<nil>")
+		return
+	case reflect.Interface:
+		p.writeRedacted(b, v.Elem())
+		return
+	case reflect.Ptr:
+		if v.IsNil() {
+			b.WriteString("<nil>")
+			return
+		}
+		if p.redactsInto(v.Type().Elem()) {
+			b.WriteByte('&')
+			p.writeRedacted(b, v.Elem())
+			return
+		}
+	case reflect.Struct:
+		if p.redactsInto(v.Type()) {
+			b.WriteByte('{')
+			for i := 0; i < v.NumField(); i++ {
+				if i > 0 {
+					b.WriteByte(' ')
+				}
+				f := v.Type().Field(i)
+				b.WriteString(f.Name)
+				b.WriteByte(':')
+				_, opts := parseTag(f.Tag.Get(p.tagName()))
+				if isTrue(opts.tags(f.Tag).Get("secret")) {
+					b.WriteString(redacted)
+					continue
+				}
+				p.writeRedacted(b, v.Field(i))
+			}
+			b.WriteByte('}')
+			return
+		}
+	case reflect.Slice, reflect.Array:
+		if p.redactsInto(v.Type().Elem()) {
+			if v.Kind() == reflect.Slice && v.IsNil() {
+				b.WriteString("[]")
+				return
+			}
+			b.WriteByte('[')
+			for i := 0; i < v.Len(); i++ {
+				if i > 0 {
+					b.WriteByte(' ')
+				}
+				p.writeRedacted(b, v.Index(i))
+			}
+			b.WriteByte(']')
+			return
+		}
+	}
+	fmt.Fprintf(b, "%+v", v)
+}
+
+// redactsInto reports whether values of type t are formatted field by field,
+// which is the case for structs and pointers to structs that are sections.
+func (p *Processor) redactsInto(t reflect.Type) bool {
+	typ := t
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return p.isSection(t, typ, "")
+}
@@ -0,0 +1,48 @@
+// Copyright (c) 2016 Kelsey Hightower and others. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package envconfig
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRedacted(t *testing.T) {
+	type database struct {
+		Host     string
+		Password string `secret:"true"`
+	}
+	type config struct {
+		Port     int
+		APIKey   string `envconfig:"API_KEY,secret"`
+		Started  time.Time
+		DB       database
+		Replica  *database
+		Backup   *database
+		Users    []database
+		Tags     []string
+		internal string
+	}
+	c := config{
+		Port:     8080,
+		APIKey:   "hunter2",
+		Started:  time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		DB:       database{Host: "db", Password: "s3cret"},
+		Replica:  &database{Host: "replica", Password: "s3cret"},
+		Users:    []database{{Host: "u", Password: "p"}},
+		Tags:     []string{"a", "b"},
+		internal: "x",
+	}
+
+	want := "&{Port:8080 APIKey:*** Started:2020-01-02 03:04:05 +0000 UTC " +
+		"DB:{Host:db Password:***} Replica:&{Host:replica Password:***} Backup:<nil> " +
+		"Users:[{Host:u Password:***}] Tags:[a b] internal:x}"
+	if got := Redacted(&c); got != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, got)
+	}
+	if got := Redacted(nil); got != "<nil>" {
+		t.Errorf("expected <nil>, got %s", got)
+	}
+}
@@ -0,0 +1,212 @@
+// Copyright (c) 2016 Kelsey Hightower and others. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package envconfig
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Violation is the kind of problem a Processor in strict mode reports.
+type Violation int
+
+const (
+	// ViolationUnknownTag means a field has a tag or tag option that
+	// envconfig does not know, such as a misspelt requird:"true".
+	ViolationUnknownTag Violation = iota
+	// ViolationUnsupportedType means a variable is set for a field whose
+	// type cannot be parsed.
+	ViolationUnsupportedType
+	// ViolationUnknownVar means a variable with the prefix is set that no
+	// field reads.
+	ViolationUnknownVar
+)
+
+func (v Violation) String() string {
+	switch v {
+	case ViolationUnknownTag:
+		return "unknown tag"
+	case ViolationUnsupportedType:
+		return "unsupported type"
+	case ViolationUnknownVar:
+		return "unknown variable"
+	}
+	return "Violation(" + strconv.Itoa(int(v)) + ")"
+}
+
+// StrictError is a violation found by a Processor in strict mode.
+type StrictError struct {
+	Violation Violation
+	KeyName   string // the variable, unless the violation is a tag
+	FieldName string // the field, unless the violation is a variable
+	Tag       string // the unknown tag or option
+}
+
+func (e *StrictError) Error() string {
+	switch e.Violation {
+	case ViolationUnknownTag:
+		return fmt.Sprintf("strict: unknown tag %s on field %s", e.Tag, e.FieldName)
+	case ViolationUnsupportedType:
+		return fmt.Sprintf("strict: %s is set for field %s of unsupported type", e.KeyName, e.FieldName)
+	}
+	return fmt.Sprintf("strict: unknown environment variable %s", e.KeyName)
+}
+
+// knownTags are the tags envconfig reads besides the one named by TagName.
+var knownTags = map[string]bool{
+	"aliases": true, "base": true, "bool": true, "char": true,
+	"default": true, "default_on_empty": true, "desc": true, "description": true,
+	"encoding": true, "expand": true, "expand_strict": true, "format": true,
+	"grouped": true, "ignored": true, "infer": true, "kv_separator": true,
+	"max": true, "min": true, "negate": true, "oneof": true,
+	"oneof_ignore_case": true, "pattern": true, "required": true,
+	"required_if": true, "secret": true, "separator": true, "split_words": true,
+	"template": true, "template_strict": true, "trim": true, "unit": true,
+}
+
+// knownOptions are the options of the envconfig tag that are not tags.
+var knownOptions = map[string]bool{"exact": true, "noalt": true, "noprefix": true}
+
+// checkStrict reports every violation of strict mode in infos as Errors of
+// StrictError, ordered by violation and then by field.
+func (p *Processor) checkStrict(prefix string, spec interface{}, infos []varInfo, lookup lookupFunc) error {
+	var tags, types, vars Errors
+	for _, info := range infos {
+		for _, tag := range p.unknownTags(info) {
+			tags = append(tags, &StrictError{Violation: ViolationUnknownTag, FieldName: info.Name, Tag: tag})
+		}
+
+		// problems other than the type are left to processing
+		r, ok, err := p.resolveInfo(info, lookup)
+		if err != nil || !ok {
+			continue
+		}
+		scratch := reflect.New(info.Field.Type()).Elem()
+		if errors.Is(p.processField(r.value, scratch, info.Tags), ErrUnsupportedFieldType) {
+			types = append(types, &StrictError{Violation: ViolationUnsupportedType, KeyName: r.key, FieldName: info.Name})
+		}
+	}
+	// without a prefix every variable of the environment would be unknown
+	if strings.TrimSpace(p.specPrefix(p.typ, prefix)) != "" {
+		for _, key := range p.unknownVars(prefix, spec, infos) {
+			vars = append(vars, &StrictError{Violation: ViolationUnknownVar, KeyName: key})
+		}
+	}
+
+	errs := append(append(tags, types...), vars...)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// unknownTags returns the options of the envconfig tag of info that are
+// unknown, and the other tags that look like a misspelling of a known one.
+// Tags of other packages, such as json, are left alone.
+func (p *Processor) unknownTags(info varInfo) []string {
+	var unknown []string
+	seen := make(map[string]bool)
+	_, opts := parseTag(info.Tags.Get(p.tagName()))
+	for _, opt := range strings.Split(string(opts), ",") {
+		key := strings.TrimSpace(opt)
+		if i := strings.Index(key, "="); i >= 0 {
+			key = strings.TrimSpace(key[:i])
+		}
+		// options become tags, so they are not checked again below
+		seen[key] = true
+		if key != "" && !knownTags[key] && !knownOptions[key] {
+			unknown = append(unknown, key)
+		}
+	}
+
+	for _, key := range tagKeys(info.Tags) {
+		if seen[key] || key == p.tagName() || knownTags[key] {
+			continue
+		}
+		seen[key] = true
+		if misspelt(key) {
+			unknown = append(unknown, key)
+		}
+	}
+	return unknown
+}
+
+// misspelt reports whether key is close to a known tag. Short keys may differ
+// by one edit, longer ones by two.
+func misspelt(key string) bool {
+	limit := 1
+	if len(key) > 5 {
+		limit = 2
+	}
+	for known := range knownTags {
+		if editDistance(key, known) <= limit {
+			return true
+		}
+	}
+	return false
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = prev[j-1] + cost
+			if d := prev[j] + 1; d < cur[j] {
+				cur[j] = d
+			}
+			if d := cur[j-1] + 1; d < cur[j] {
+				cur[j] = d
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// tagKeys returns the keys of tag in the order they are written, following
+// the conventional syntax that reflect.StructTag.Get parses.
+func tagKeys(tag reflect.StructTag) []string {
+	var keys []string
+	s := string(tag)
+	for s != "" {
+		s = strings.TrimLeft(s, " ")
+		i := 0
+		for i < len(s) && s[i] > ' ' && s[i] != ':' && s[i] != '"' && s[i] != 0x7f {
+			i++
+		}
+		if i == 0 || i+1 >= len(s) || s[i] != ':' || s[i+1] != '"' {
+			break
+		}
+		keys = append(keys, s[:i])
+		s = s[i+1:]
+
+		// skip the quoted value
+		i = 1
+		for i < len(s) && s[i] != '"' {
+			if s[i] == '\\' {
+				i++
+			}
+			i++
+		}
+		if i >= len(s) {
+			break
+		}
+		s = s[i+1:]
+	}
+	return keys
+}
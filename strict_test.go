@@ -0,0 +1,143 @@
+// Copyright (c) 2016 Kelsey Hightower and others. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package envconfig
+
+import (
+	"os"
+	"testing"
+)
+
+type strictSpec struct {
+	Port    int    `requird:"true"`
+	Host    string `envconfig:"HOST,noalt,requred"`
+	Name    string `json:"name" yaml:"name" form:"name"`
+	Size    int    `defualt:"1"`
+	Updates chan string
+	Debug   bool
+}
+
+func TestProcessorStrict(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_UPDATES", "x")
+	os.Setenv("ENV_CONFIG_PROT", "8080")
+	os.Setenv("ENV_CONFIG_DEBUG", "true")
+
+	p, err := NewProcessor(&strictSpec{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Strict = true
+	var s strictSpec
+	err = p.Process("env_config", &s)
+	errs, ok := err.(Errors)
+	if !ok {
+		t.Fatalf("expected Errors, got %v", err)
+	}
+
+	want := []StrictError{
+		{Violation: ViolationUnknownTag, FieldName: "Port", Tag: "requird"},
+		{Violation: ViolationUnknownTag, FieldName: "Host", Tag: "requred"},
+		{Violation: ViolationUnknownTag, FieldName: "Size", Tag: "defualt"},
+		{Violation: ViolationUnsupportedType, KeyName: "ENV_CONFIG_UPDATES", FieldName: "Updates"},
+		{Violation: ViolationUnknownVar, KeyName: "ENV_CONFIG_PROT"},
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("expected %d violations, got %d: %v", len(want), len(errs), errs)
+	}
+	for i, w := range want {
+		se, ok := errs[i].(*StrictError)
+		if !ok || *se != w {
+			t.Errorf("%d: expected %+v, got %v", i, w, errs[i])
+		}
+	}
+	if s.Debug {
+		t.Error("expected nothing to be assigned")
+	}
+	if want := "strict: unknown tag requird on field Port"; errs[0].Error() != want {
+		t.Errorf("expected %q, got %q", want, errs[0])
+	}
+
+	type clean struct {
+		Port int    `default:"80" required:"true"`
+		Name string `json:"name" envconfig:"NAME,noprefix"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_PORT", "8080")
+	p, err = NewProcessor(&clean{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Strict = true
+	var c clean
+	if err := p.Process("env_config", &c); err != nil {
+		t.Fatal(err.Error())
+	}
+	if c.Port != 8080 {
+		t.Errorf("expected %d, got %d", 8080, c.Port)
+	}
+}
+
+func TestViolationString(t *testing.T) {
+	for v, want := range map[Violation]string{
+		ViolationUnknownTag:      "unknown tag",
+		ViolationUnsupportedType: "unsupported type",
+		ViolationUnknownVar:      "unknown variable",
+		Violation(7):             "Violation(7)",
+	} {
+		if v.String() != want {
+			t.Errorf("expected %q, got %q", want, v.String())
+		}
+	}
+}
+
+func TestProcessorStrictUnknownVars(t *testing.T) {
+	type spec struct {
+		Port int
+	}
+	strict := func(separator, secondary string) *Processor {
+		p, err := NewProcessor(&spec{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.Strict, p.Separator, p.SecondaryPrefix = true, separator, secondary
+		return p
+	}
+	var s spec
+
+	os.Clearenv()
+	os.Setenv("HOME", "/root")
+	os.Setenv("PORT", "80")
+	if err := strict("", "").Process("", &s); err != nil {
+		t.Errorf("expected no unknown variables without a prefix, got %v", err)
+	}
+
+	os.Clearenv()
+	os.Setenv("APP.PORT", "80")
+	os.Setenv("APP.X", "1")
+	os.Setenv("APP_OTHER", "1")
+	err := strict(".", "").Process("app", &s)
+	errs, ok := err.(Errors)
+	if !ok || len(errs) != 1 || errs[0].(*StrictError).KeyName != "APP.X" {
+		t.Errorf("expected APP.X to be unknown, got %v", err)
+	}
+
+	os.Clearenv()
+	os.Setenv("PLATFORM_PORT", "80")
+	os.Setenv("PLATFORM_X", "1")
+	err = strict("", "platform").Process("app", &s)
+	errs, ok = err.(Errors)
+	if !ok || len(errs) != 1 || errs[0].(*StrictError).KeyName != "PLATFORM_X" {
+		t.Errorf("expected PLATFORM_X to be unknown, got %v", err)
+	}
+
+	os.Clearenv()
+	os.Setenv("APP_X", "1")
+	defer SetDefaultLookup(func(key string) (string, bool) {
+		return "80", key == "APP_PORT"
+	})()
+	if err := strict("", "").Process("app", &s); err != nil {
+		t.Errorf("expected variables outside the default lookup to be left out, got %v", err)
+	}
+}
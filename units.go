@@ -0,0 +1,167 @@
+// Copyright (c) 2016 Kelsey Hightower and others. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package envconfig
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// byteUnits maps the suffixes understood by the unit:"bytes" tag to their
+// size. Decimal suffixes (KB, MB, ...) are powers of 1000 and binary suffixes
+// (KiB, MiB, ...) are powers of 1024. Suffixes are matched case-insensitively.
+var byteUnits = map[string]uint64{
+	"":    1,
+	"B":   1,
+	"KB":  1000,
+	"MB":  1000 * 1000,
+	"GB":  1000 * 1000 * 1000,
+	"TB":  1000 * 1000 * 1000 * 1000,
+	"PB":  1000 * 1000 * 1000 * 1000 * 1000,
+	"KIB": 1 << 10,
+	"MIB": 1 << 20,
+	"GIB": 1 << 30,
+	"TIB": 1 << 40,
+	"PIB": 1 << 50,
+}
+
+// splitByteSize splits a size such as "512MB" into its number and the
+// multiplier of its unit.
+func splitByteSize(value string) (string, uint64, error) {
+	value = strings.TrimSpace(value)
+	i := len(value)
+	for i > 0 && (value[i-1] >= 'a' && value[i-1] <= 'z' || value[i-1] >= 'A' && value[i-1] <= 'Z') {
+		i--
+	}
+	number, unit := strings.TrimSpace(value[:i]), value[i:]
+	mult, ok := byteUnits[strings.ToUpper(unit)]
+	if !ok {
+		return "", 0, fmt.Errorf("unknown byte size unit %q", unit)
+	}
+	return number, mult, nil
+}
+
+// parseIntBytes parses a byte size into a signed integer of the given size.
+func parseIntBytes(value string, bitSize int) (int64, error) {
+	number, mult, err := splitByteSize(value)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.ParseInt(number, 10, bitSize)
+	if err != nil {
+		return 0, err
+	}
+	max := int64(^uint64(0) >> uint(65-bitSize))
+	// the range is one larger below zero, so the minimum is -max-1
+	if n > max/int64(mult) || n < (-max-1)/int64(mult) {
+		return 0, &strconv.NumError{Func: "ParseInt", Num: value, Err: strconv.ErrRange}
+	}
+	return n * int64(mult), nil
+}
+
+// parseUintBytes parses a byte size into an unsigned integer of the given
+// size.
+func parseUintBytes(value string, bitSize int) (uint64, error) {
+	number, mult, err := splitByteSize(value)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.ParseUint(number, 10, bitSize)
+	if err != nil {
+		return 0, err
+	}
+	max := ^uint64(0) >> uint(64-bitSize)
+	if n > max/mult {
+		return 0, &strconv.NumError{Func: "ParseUint", Num: value, Err: strconv.ErrRange}
+	}
+	return n * mult, nil
+}
+
+// durationUnits maps the values of the unit tag of a time.Duration to the
+// unit a bare number is read in.
+var durationUnits = map[string]time.Duration{
+	"ns": time.Nanosecond,
+	"us": time.Microsecond,
+	"µs": time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+}
+
+// parseDuration parses a duration. A bare number is read in unit, if given,
+// anything else as understood by time.ParseDuration.
+func parseDuration(value, unit string) (time.Duration, error) {
+	if unit == "" {
+		return time.ParseDuration(value)
+	}
+	mult, ok := durationUnits[unit]
+	if !ok {
+		return 0, fmt.Errorf("unknown duration unit %q", unit)
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		// not a number, such as 1m30s
+		return time.ParseDuration(value)
+	}
+	d := n * float64(mult)
+	// float64(math.MaxInt64) rounds up to 1<<63, which no longer fits
+	if math.IsNaN(d) || d >= math.MaxInt64 || d < math.MinInt64 {
+		return 0, &strconv.NumError{Func: "ParseDuration", Num: value, Err: strconv.ErrRange}
+	}
+	return time.Duration(d), nil
+}
+
+// ungroup removes the digit group separators from a number such as 1_000_000
+// or 1,000,000. Underscores may separate any two digits, commas only groups
+// of three digits in the integer part.
+func ungroup(value string) (string, error) {
+	var b strings.Builder
+	group := -1 // digits since the last comma, -1 before the first one
+	digits := 0 // digits in the integer part so far
+	integer := true
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c >= '0' && c <= '9':
+			if integer {
+				digits++
+				if group >= 0 {
+					group++
+				}
+			}
+		case c == '_' || c == ',':
+			if i == 0 || !isDigit(value[i-1]) || i+1 == len(value) || !isDigit(value[i+1]) {
+				return "", fmt.Errorf("misplaced digit group separator in %q", value)
+			}
+			if c == ',' {
+				if !integer || group >= 0 && group != 3 || group < 0 && digits > 3 {
+					return "", fmt.Errorf("invalid digit grouping in %q", value)
+				}
+				group = 0
+			}
+			continue
+		default:
+			if integer && group >= 0 && group != 3 {
+				return "", fmt.Errorf("invalid digit grouping in %q", value)
+			}
+			if c != '+' && c != '-' || i > 0 {
+				integer = false
+			}
+		}
+		b.WriteByte(c)
+	}
+	if integer && group >= 0 && group != 3 {
+		return "", fmt.Errorf("invalid digit grouping in %q", value)
+	}
+	return b.String(), nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
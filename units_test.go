@@ -0,0 +1,211 @@
+// Copyright (c) 2016 Kelsey Hightower and others. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package envconfig
+
+import (
+	"math"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestByteSizes(t *testing.T) {
+	var s struct {
+		Plain   int64  `unit:"bytes"`
+		Decimal uint64 `unit:"bytes"`
+		Binary  int    `unit:"bytes"`
+		Lower   uint32 `unit:"bytes"`
+		Bytes   int    `unit:"bytes"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_PLAIN", "512")
+	os.Setenv("ENV_CONFIG_DECIMAL", "512MB")
+	os.Setenv("ENV_CONFIG_BINARY", "2 GiB")
+	os.Setenv("ENV_CONFIG_LOWER", "4kib")
+	os.Setenv("ENV_CONFIG_BYTES", "10B")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if s.Plain != 512 {
+		t.Errorf("expected %d, got %d", 512, s.Plain)
+	}
+	if s.Decimal != 512*1000*1000 {
+		t.Errorf("expected %d, got %d", 512*1000*1000, s.Decimal)
+	}
+	if s.Binary != 2<<30 {
+		t.Errorf("expected %d, got %d", 2<<30, s.Binary)
+	}
+	if s.Lower != 4096 {
+		t.Errorf("expected %d, got %d", 4096, s.Lower)
+	}
+	if s.Bytes != 10 {
+		t.Errorf("expected %d, got %d", 10, s.Bytes)
+	}
+}
+
+func TestByteSizeErrors(t *testing.T) {
+	for _, value := range []string{"12XB", "MB", "1.5GB", "8GiB"} {
+		var s struct {
+			Size uint32 `unit:"bytes"`
+		}
+		os.Clearenv()
+		os.Setenv("ENV_CONFIG_SIZE", value)
+		err := Process("env_config", &s)
+		if _, ok := err.(*ParseError); !ok {
+			t.Errorf("%s: expected ParseError, got %v", value, err)
+		}
+	}
+}
+
+func TestDurationUnits(t *testing.T) {
+	var s struct {
+		Timeout  time.Duration   `unit:"s"`
+		Interval time.Duration   `unit:"ms"`
+		Suffixed time.Duration   `unit:"s"`
+		Backoff  []time.Duration `unit:"m"`
+		Plain    time.Duration
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_TIMEOUT", "30")
+	os.Setenv("ENV_CONFIG_INTERVAL", "1.5")
+	os.Setenv("ENV_CONFIG_SUFFIXED", "30s")
+	os.Setenv("ENV_CONFIG_BACKOFF", "1,90s")
+	os.Setenv("ENV_CONFIG_PLAIN", "0")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Timeout != 30*time.Second {
+		t.Errorf("expected %v, got %v", 30*time.Second, s.Timeout)
+	}
+	if s.Interval != 1500*time.Microsecond {
+		t.Errorf("expected %v, got %v", 1500*time.Microsecond, s.Interval)
+	}
+	if s.Suffixed != 30*time.Second {
+		t.Errorf("expected %v, got %v", 30*time.Second, s.Suffixed)
+	}
+	if len(s.Backoff) != 2 || s.Backoff[0] != time.Minute || s.Backoff[1] != 90*time.Second {
+		t.Errorf("expected [1m0s 1m30s], got %v", s.Backoff)
+	}
+
+	for _, value := range []string{"garbage", "30x", "1e300"} {
+		os.Setenv("ENV_CONFIG_TIMEOUT", value)
+		if _, ok := Process("env_config", &s).(*ParseError); !ok {
+			t.Errorf("%s: expected ParseError", value)
+		}
+	}
+
+	var bad struct {
+		Timeout time.Duration `unit:"days"`
+	}
+	os.Setenv("ENV_CONFIG_TIMEOUT", "1")
+	if _, ok := Process("env_config", &bad).(*ParseError); !ok {
+		t.Error("expected ParseError for an unknown unit")
+	}
+}
+
+func TestGroupedNumbers(t *testing.T) {
+	var s struct {
+		Limit   int64   `grouped:"true"`
+		Quota   uint    `grouped:"true"`
+		Rate    float64 `grouped:"true"`
+		Delta   int     `grouped:"true"`
+		Sizes   []int   `grouped:"true" separator:";"`
+		Literal int
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_LIMIT", "1_000_000")
+	os.Setenv("ENV_CONFIG_QUOTA", "1,000,000")
+	os.Setenv("ENV_CONFIG_RATE", "12,345.678_9")
+	os.Setenv("ENV_CONFIG_DELTA", "-1,000")
+	os.Setenv("ENV_CONFIG_SIZES", "1,024;2_048")
+	os.Setenv("ENV_CONFIG_LITERAL", "1000")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Limit != 1000000 || s.Quota != 1000000 || s.Delta != -1000 {
+		t.Errorf("expected 1000000, 1000000 and -1000, got %d, %d and %d", s.Limit, s.Quota, s.Delta)
+	}
+	if s.Rate != 12345.6789 {
+		t.Errorf("expected %v, got %v", 12345.6789, s.Rate)
+	}
+	if len(s.Sizes) != 2 || s.Sizes[0] != 1024 || s.Sizes[1] != 2048 {
+		t.Errorf("expected [1024 2048], got %v", s.Sizes)
+	}
+
+	for _, value := range []string{"1,00", "1234,567", "1__000", "_1", "1_", ",100", "1,000,00", "1.000,5"} {
+		os.Setenv("ENV_CONFIG_LIMIT", value)
+		if _, ok := Process("env_config", &s).(*ParseError); !ok {
+			t.Errorf("%s: expected ParseError", value)
+		}
+	}
+
+	os.Setenv("ENV_CONFIG_LIMIT", "1")
+	os.Setenv("ENV_CONFIG_LITERAL", "1,000")
+	if _, ok := Process("env_config", &s).(*ParseError); !ok {
+		t.Error("expected ParseError for a grouped number without the tag")
+	}
+}
+
+func TestNegativeNumbers(t *testing.T) {
+	var s struct {
+		Offset   time.Duration
+		Skew     time.Duration `unit:"s"`
+		Delta    int
+		Hex      int8 `base:"0"`
+		Ratio    float64
+		Grouped  int64   `grouped:"true"`
+		Balance  float32 `grouped:"true"`
+		Shrink   int64   `unit:"bytes"`
+		MinInt64 int64   `unit:"bytes"`
+		Bounded  int     `min:"-10" max:"-1"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_OFFSET", "-5s")
+	os.Setenv("ENV_CONFIG_SKEW", "-1.5")
+	os.Setenv("ENV_CONFIG_DELTA", "-100")
+	os.Setenv("ENV_CONFIG_HEX", "-0x80")
+	os.Setenv("ENV_CONFIG_RATIO", "-1.5")
+	os.Setenv("ENV_CONFIG_GROUPED", "-1,000,000")
+	os.Setenv("ENV_CONFIG_BALANCE", "-1_000.5")
+	os.Setenv("ENV_CONFIG_SHRINK", "-2KiB")
+	os.Setenv("ENV_CONFIG_MININT64", "-9223372036854775808")
+	os.Setenv("ENV_CONFIG_BOUNDED", "-5")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if s.Offset != -5*time.Second {
+		t.Errorf("expected %v, got %v", -5*time.Second, s.Offset)
+	}
+	if s.Skew != -1500*time.Millisecond {
+		t.Errorf("expected %v, got %v", -1500*time.Millisecond, s.Skew)
+	}
+	if s.Delta != -100 || s.Hex != -128 || s.Ratio != -1.5 {
+		t.Errorf("expected -100, -128 and -1.5, got %d, %d and %v", s.Delta, s.Hex, s.Ratio)
+	}
+	if s.Grouped != -1000000 || s.Balance != -1000.5 {
+		t.Errorf("expected -1000000 and -1000.5, got %d and %v", s.Grouped, s.Balance)
+	}
+	if s.Shrink != -2048 || s.MinInt64 != math.MinInt64 {
+		t.Errorf("expected -2048 and %d, got %d and %d", int64(math.MinInt64), s.Shrink, s.MinInt64)
+	}
+	if s.Bounded != -5 {
+		t.Errorf("expected %d, got %d", -5, s.Bounded)
+	}
+
+	for key, value := range map[string]string{
+		"HEX":      "-0x81",
+		"SHRINK":   "-10000PB",
+		"MININT64": "-9223372036854775809",
+		"SKEW":     "-9223372036854775808",
+	} {
+		os.Setenv("ENV_CONFIG_"+key, value)
+		if _, ok := Process("env_config", &s).(*ParseError); !ok {
+			t.Errorf("%s=%s: expected ParseError", key, value)
+		}
+		os.Clearenv()
+	}
+}
@@ -54,15 +54,29 @@ func implementsInterface(t reflect.Type) bool {
 		reflect.PtrTo(t).Implements(binaryUnmarshalerType)
 }
 
+// typeDescription describes the type of the value of info like
+// toTypeDescription, which does not know that JSON values can be of any type.
+func typeDescription(info varInfo) string {
+	if info.Tags.Get("encoding") == "json" {
+		return "JSON"
+	}
+	return toTypeDescription(info.Field.Type())
+}
+
 // toTypeDescription converts Go types into a human readable description
 func toTypeDescription(t reflect.Type) string {
 	switch t.Kind() {
 	case reflect.Array, reflect.Slice:
-		if t.Elem().Kind() == reflect.Uint8 {
+		// byte slices hold the value itself, byte arrays a list of numbers
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
 			return "String"
 		}
 		return fmt.Sprintf("Comma-separated list of %s", toTypeDescription(t.Elem()))
 	case reflect.Map:
+		// sets are read like slices
+		if t.Elem().Kind() == reflect.Struct && t.Elem().NumField() == 0 {
+			return fmt.Sprintf("Comma-separated list of %s", toTypeDescription(t.Key()))
+		}
 		return fmt.Sprintf(
 			"Comma-separated list of %s:%s pairs",
 			toTypeDescription(t.Key()),
@@ -71,6 +85,9 @@ func toTypeDescription(t reflect.Type) string {
 	case reflect.Ptr:
 		return toTypeDescription(t.Elem())
 	case reflect.Struct:
+		if t == ipNetType {
+			return "CIDR"
+		}
 		if implementsInterface(t) && t.Name() != "" {
 			return t.Name()
 		}
@@ -126,12 +143,17 @@ func Usagef(prefix string, spec interface{}, out io.Writer, format string) error
 	// Specify the default usage template functions
 	functions := template.FuncMap{
 		"usage_key":         func(v varInfo) string { return v.Key },
-		"usage_description": func(v varInfo) string { return v.Tags.Get("desc") },
-		"usage_type":        func(v varInfo) string { return toTypeDescription(v.Field.Type()) },
-		"usage_default":     func(v varInfo) string { return v.Tags.Get("default") },
+		"usage_description": func(v varInfo) string { return v.description() },
+		"usage_type":        func(v varInfo) string { return typeDescription(v) },
+		"usage_default": func(v varInfo) string {
+			if def := v.Tags.Get("default"); def != "" && v.secret() {
+				return redacted
+			}
+			return v.Tags.Get("default")
+		},
 		"usage_required": func(v varInfo) (string, error) {
 			req := v.Tags.Get("required")
-			if req != "" {
+			if req != "" && req != requiredNonEmpty {
 				reqB, err := strconv.ParseBool(req)
 				if err != nil {
 					return "", err
@@ -155,10 +177,96 @@ func Usagef(prefix string, spec interface{}, out io.Writer, format string) error
 // Usaget writes usage information to the specified io.Writer using the specified template
 func Usaget(prefix string, spec interface{}, out io.Writer, tmpl *template.Template) error {
 	// gather first
-	infos, err := gatherInfo(prefix, spec)
+	p := &Processor{}
+	infos, err := p.gatherInfo(prefix, spec)
+	if err != nil {
+		return err
+	}
+	infos, err = p.usageLists(infos, nil)
 	if err != nil {
 		return err
 	}
 
 	return tmpl.Execute(out, infos)
 }
+
+// usageLists replaces every list of sections in infos by the variables of an
+// element, with <N> standing for the index in their keys. within holds the
+// element types already being described; a list of one of them, such as the
+// children of a tree, repeats their variables and is left out.
+func (p *Processor) usageLists(infos []varInfo, within []reflect.Type) ([]varInfo, error) {
+	var expanded []varInfo
+	for _, info := range infos {
+		if info.elem == nil {
+			expanded = append(expanded, info)
+			continue
+		}
+		if containsType(within, info.elem) {
+			continue
+		}
+		elemInfos, err := p.gatherElem(info, "<N>")
+		if err != nil {
+			return nil, err
+		}
+		bindInfos(reflect.New(info.elem).Elem(), elemInfos)
+		elemInfos, err = p.usageLists(elemInfos, append(within, info.elem))
+		if err != nil {
+			return nil, err
+		}
+		expanded = append(expanded, elemInfos...)
+	}
+	return expanded, nil
+}
+
+// KeyInfo describes a variable that a specification reads.
+type KeyInfo struct {
+	// Key is the name of the environment variable, with <N> standing for the
+	// index of list elements.
+	Key string
+	// FieldName is the name of the struct field the variable is assigned to.
+	FieldName string
+	// Type is the human readable type, as shown by Usage.
+	Type string
+	// Default is the default value, masked for secret fields.
+	Default string
+	// Required reports whether the variable must be set.
+	Required bool
+	// Secret reports whether the field is tagged secret.
+	Secret bool
+	// Description is taken from the desc or description tag.
+	Description string
+}
+
+// Keys returns every variable that Process would read for spec, in the order
+// the fields are declared and nested fields included. It is the programmatic
+// counterpart to Usage, for generating deployment templates or a sample .env.
+func Keys(prefix string, spec interface{}) ([]KeyInfo, error) {
+	p := &Processor{}
+	infos, err := p.gatherInfo(prefix, spec)
+	if err != nil {
+		return nil, err
+	}
+	infos, err = p.usageLists(infos, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]KeyInfo, len(infos))
+	for i, info := range infos {
+		def := info.Tags.Get("default")
+		if def != "" && info.secret() {
+			def = redacted
+		}
+		req := info.Tags.Get("required")
+		keys[i] = KeyInfo{
+			Key:         info.Key,
+			FieldName:   info.Name,
+			Type:        typeDescription(info),
+			Default:     def,
+			Required:    req == requiredNonEmpty || isTrue(req),
+			Secret:      info.secret(),
+			Description: info.description(),
+		}
+	}
+	return keys, nil
+}
@@ -9,7 +9,9 @@ import (
 	"io"
 	"io/ioutil"
 	"log"
+	"net"
 	"os"
+	"reflect"
 	"strings"
 	"testing"
 	"text/tabwriter"
@@ -153,3 +155,127 @@ func TestUsageBadFormat(t *testing.T) {
 	}
 	compareUsage(testUsageBadFormatResult, buf.String(), t)
 }
+
+func TestUsageDescriptionTag(t *testing.T) {
+	var s struct {
+		DSN  string `description:"postgres connection string"`
+		Port int    `desc:"short form" description:"long form"`
+	}
+	buf := new(bytes.Buffer)
+	err := Usagef("env_config", &s, buf, "{{range .}}{{usage_key .}}={{usage_description .}}\n{{end}}")
+	if err != nil {
+		t.Error(err.Error())
+	}
+	want := "ENV_CONFIG_DSN=postgres connection string\nENV_CONFIG_PORT=short form\n"
+	if buf.String() != want {
+		t.Errorf("expected %q, got %q", want, buf.String())
+	}
+}
+
+func TestUsageSecretDefault(t *testing.T) {
+	var s struct {
+		Password string `secret:"true" default:"changeme"`
+		Host     string `default:"localhost"`
+	}
+	buf := new(bytes.Buffer)
+	err := Usagef("env_config", &s, buf, "{{range .}}{{usage_key .}}={{usage_default .}}\n{{end}}")
+	if err != nil {
+		t.Error(err.Error())
+	}
+	want := "ENV_CONFIG_PASSWORD=***\nENV_CONFIG_HOST=localhost\n"
+	if buf.String() != want {
+		t.Errorf("expected %q, got %q", want, buf.String())
+	}
+}
+
+func TestUsageRequiredNonEmpty(t *testing.T) {
+	var s struct {
+		APIKey string `required:"nonempty"`
+		Port   int    `required:"1"`
+	}
+	buf := new(bytes.Buffer)
+	err := Usagef("env_config", &s, buf, "{{range .}}{{usage_key .}}={{usage_required .}}\n{{end}}")
+	if err != nil {
+		t.Error(err.Error())
+	}
+	want := "ENV_CONFIG_APIKEY=nonempty\nENV_CONFIG_PORT=true\n"
+	if buf.String() != want {
+		t.Errorf("expected %q, got %q", want, buf.String())
+	}
+}
+
+func TestUsageSectionLists(t *testing.T) {
+	var s struct {
+		Servers []struct {
+			Host string
+			Port int
+		}
+	}
+	buf := new(bytes.Buffer)
+	err := Usagef("env_config", &s, buf, "{{range .}}{{usage_key .}}={{usage_type .}}\n{{end}}")
+	if err != nil {
+		t.Error(err.Error())
+	}
+	want := "ENV_CONFIG_SERVERS_<N>_HOST=String\nENV_CONFIG_SERVERS_<N>_PORT=Integer\n"
+	if buf.String() != want {
+		t.Errorf("expected %q, got %q", want, buf.String())
+	}
+}
+
+func TestKeys(t *testing.T) {
+	var s struct {
+		Port     int    `default:"8080" desc:"listen port"`
+		Password string `required:"true" secret:"true" default:"hunter2"`
+		DB       struct {
+			Host string `required:"nonempty"`
+		}
+		Servers []struct {
+			Host string
+		}
+	}
+	keys, err := Keys("env_config", &s)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	want := []KeyInfo{
+		{Key: "ENV_CONFIG_PORT", FieldName: "Port", Type: "Integer", Default: "8080", Description: "listen port"},
+		{Key: "ENV_CONFIG_PASSWORD", FieldName: "Password", Type: "String", Default: redacted, Required: true, Secret: true},
+		{Key: "ENV_CONFIG_DB_HOST", FieldName: "Host", Type: "String", Required: true},
+		{Key: "ENV_CONFIG_SERVERS_<N>_HOST", FieldName: "Host", Type: "String"},
+	}
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("expected %+v, got %+v", want, keys)
+	}
+
+	if _, err := Keys("env_config", s); err != ErrInvalidSpecification {
+		t.Errorf("expected ErrInvalidSpecification, got %v", err)
+	}
+}
+
+func TestTypeDescription(t *testing.T) {
+	for _, c := range []struct {
+		typ  reflect.Type
+		want string
+	}{
+		{reflect.TypeOf([]byte(nil)), "String"},
+		{reflect.TypeOf([4]byte{}), "Comma-separated list of Unsigned Integer"},
+		{reflect.TypeOf(map[string]struct{}{}), "Comma-separated list of String"},
+		{reflect.TypeOf(map[string]int{}), "Comma-separated list of String:Integer pairs"},
+		{reflect.TypeOf(net.IPNet{}), "CIDR"},
+		{reflect.TypeOf(&net.IPNet{}), "CIDR"},
+	} {
+		if got := toTypeDescription(c.typ); got != c.want {
+			t.Errorf("%v: expected %q, got %q", c.typ, c.want, got)
+		}
+	}
+
+	var s struct {
+		Routes struct {
+			Path string
+		} `encoding:"json"`
+	}
+	keys, err := Keys("env_config", &s)
+	if err != nil || len(keys) != 1 || keys[0].Type != "JSON" {
+		t.Errorf("expected a JSON key, got %+v, %v", keys, err)
+	}
+}